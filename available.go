@@ -0,0 +1,253 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Space describes how much more data the server will accept. See
+// Client.Available.
+type Space struct {
+	// Bytes that can still be stored, or -1 if unlimited.
+	Available int64
+
+	// Total bytes allowed and bytes already used, or -1 if unknown. These are
+	// only reported by "SITE QUOTA" and "SITE DF".
+	Limit int64
+	Used  int64
+
+	// The command the figures came from: "AVBL", "SITE QUOTA" or "SITE DF".
+	Source string
+}
+
+// Available reports how much data can still be stored in directory "path".
+// It uses the "AVBL" command (draft-peterson-streamlined-ftp-command-extensions)
+// if the server advertises it, and otherwise tries the "SITE QUOTA" (ProFTPD
+// mod_quotatab) and "SITE DF" extensions. An unlimited "SITE QUOTA" is only
+// returned if "SITE DF" isn't supported. If none of them work, an error is
+// returned.
+func (c *Client) Available(path string) (Space, error) {
+	pconn, err := c.getIdleConn()
+	if err != nil {
+		return Space{}, err
+	}
+
+	defer c.returnConn(pconn)
+
+	return c.available(pconn, path)
+}
+
+func (c *Client) available(pconn *persistentConn, path string) (Space, error) {
+	if pconn.hasFeature("AVBL") {
+		code, msg, err := pconn.sendCommand("AVBL %s", path)
+		if err != nil {
+			return Space{}, err
+		}
+
+		if code == replyFileStatus {
+			avail, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+			if err == nil {
+				return Space{Available: avail, Limit: -1, Used: -1, Source: "AVBL"}, nil
+			}
+			pconn.debug(`failed parsing AVBL response "%s": %s`, msg, err)
+		} else {
+			pconn.debug("unexpected AVBL response: %d (%s)", code, msg)
+		}
+	}
+
+	code, msg, err := pconn.sendCommand("SITE QUOTA")
+	if err != nil {
+		return Space{}, err
+	}
+
+	quota, haveQuota := Space{}, false
+	if positiveCompletionReply(code) {
+		quota, haveQuota = parseSiteQuota(msg)
+		if haveQuota && quota.Available != -1 {
+			return quota, nil
+		}
+	} else {
+		pconn.debug("server doesn't support SITE QUOTA: %d (%s)", code, msg)
+	}
+
+	if path == "" {
+		code, msg, err = pconn.sendCommand("SITE DF")
+	} else {
+		code, msg, err = pconn.sendCommand("SITE DF %s", path)
+	}
+	if err != nil {
+		return Space{}, err
+	}
+
+	if positiveCompletionReply(code) {
+		if df, ok := parseSiteDF(msg); ok {
+			return df, nil
+		}
+		pconn.debug("failed parsing SITE DF response: %s", msg)
+	} else {
+		pconn.debug("server doesn't support SITE DF: %d (%s)", code, msg)
+	}
+
+	if haveQuota {
+		return quota, nil
+	}
+
+	return Space{}, pconn.withContext(ftpError{err: fmt.Errorf("AVBL, SITE QUOTA and SITE DF are %w", ErrNotSupported)})
+}
+
+// Parse ProFTPD's "SITE QUOTA" reply, looking at the upload limit:
+//
+//	The current quota for this session are [current/limit]:
+//	Name: goftp
+//	Quota Type: User
+//	Per Session: False
+//	Limit Type: Hard
+//	  Uploaded bytes:	0.00/1048576.00
+//	  Downloaded bytes:	unlimited
+//	  ...
+//
+// Depending on QuotaDisplayUnits, sizes may be in Kb, Mb or Gb instead.
+func parseSiteQuota(msg string) (Space, bool) {
+	units := map[string]float64{
+		"bytes": 1,
+		"kb":    1 << 10,
+		"mb":    1 << 20,
+		"gb":    1 << 30,
+	}
+
+	for _, line := range strings.Split(msg, "\n") {
+		colon := strings.IndexByte(line, ':')
+		if colon == -1 {
+			continue
+		}
+
+		label := strings.Fields(strings.ToLower(line[:colon]))
+		if len(label) != 2 || label[0] != "uploaded" {
+			continue
+		}
+
+		unit, found := units[label[1]]
+		if !found {
+			continue
+		}
+
+		value := strings.TrimSpace(line[colon+1:])
+		if value == "unlimited" {
+			return Space{Available: -1, Limit: -1, Used: -1, Source: "SITE QUOTA"}, true
+		}
+
+		slash := strings.IndexByte(value, '/')
+		if slash == -1 {
+			return Space{}, false
+		}
+
+		used, err := strconv.ParseFloat(value[:slash], 64)
+		if err != nil {
+			return Space{}, false
+		}
+
+		limit, err := strconv.ParseFloat(value[slash+1:], 64)
+		if err != nil {
+			return Space{}, false
+		}
+
+		space := Space{
+			Limit:  int64(limit * unit),
+			Used:   int64(used * unit),
+			Source: "SITE QUOTA",
+		}
+
+		space.Available = space.Limit - space.Used
+		if space.Available < 0 {
+			space.Available = 0
+		}
+
+		return space, true
+	}
+
+	return Space{}, false
+}
+
+// Parse a "SITE DF" reply in "df" format:
+//
+//	Filesystem     1K-blocks    Used Available Use% Mounted on
+//	/dev/sda1       41152736 9254836  29784192  24% /
+func parseSiteDF(msg string) (Space, bool) {
+	lines := strings.Split(strings.TrimSpace(msg), "\n")
+
+	var (
+		header    []string
+		blockSize int64
+	)
+	for len(lines) > 0 {
+		header = strings.Fields(lines[0])
+		lines = lines[1:]
+		if len(header) > 0 && header[0] == "Filesystem" {
+			break
+		}
+		header = nil
+	}
+
+	if header == nil || len(lines) == 0 {
+		return Space{}, false
+	}
+
+	totalCol, usedCol, availCol := -1, -1, -1
+	for i, col := range header {
+		switch {
+		case strings.HasSuffix(col, "-blocks"):
+			// e.g. "1K-blocks" or "512-blocks"
+			size := strings.ToUpper(strings.TrimSuffix(col, "-blocks"))
+			multiplier := int64(1)
+			if strings.HasSuffix(size, "K") {
+				size = size[:len(size)-1]
+				multiplier = 1024
+			}
+
+			n, err := strconv.ParseInt(size, 10, 64)
+			if err != nil {
+				return Space{}, false
+			}
+			blockSize, totalCol = n*multiplier, i
+		case col == "Used":
+			usedCol = i
+		case strings.HasPrefix(col, "Avail"):
+			availCol = i
+		}
+	}
+
+	if blockSize == 0 || totalCol == -1 || usedCol == -1 || availCol == -1 {
+		return Space{}, false
+	}
+
+	// long filesystem names push the numbers onto the next line
+	fields := strings.Fields(lines[0])
+	if len(fields) == 1 && len(lines) > 1 {
+		fields = append(fields, strings.Fields(lines[1])...)
+	}
+
+	if len(fields) <= availCol {
+		return Space{}, false
+	}
+
+	var nums [3]int64
+	for i, col := range []int{totalCol, usedCol, availCol} {
+		n, err := strconv.ParseInt(fields[col], 10, 64)
+		if err != nil {
+			return Space{}, false
+		}
+		nums[i] = n * blockSize
+	}
+
+	return Space{
+		Limit:     nums[0],
+		Used:      nums[1],
+		Available: nums[2],
+		Source:    "SITE DF",
+	}, true
+}
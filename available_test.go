@@ -0,0 +1,87 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"testing"
+)
+
+func TestAvailable(t *testing.T) {
+	quotaReply := "The current quota for this session are [current/limit]:\n" +
+		"Name: goftp\n" +
+		"Quota Type: User\n" +
+		"Per Session: False\n" +
+		"Limit Type: Hard\n" +
+		"  Uploaded bytes:\t256.00/1024.00\n" +
+		"  Downloaded bytes:\tunlimited\n" +
+		"Please contact root if these entries are inaccurate"
+
+	unlimitedReply := "The current quota for this session are [current/limit]:\n" +
+		"  Uploaded Kb:\tunlimited\n" +
+		"Please contact root if these entries are inaccurate"
+
+	dfReply := "Filesystem     1K-blocks    Used Available Use% Mounted on\n" +
+		"/dev/mapper/very-long-volume-name\n" +
+		"                41152736 9254836  29784192  24% /"
+
+	cases := []struct {
+		features map[string]string
+		stubs    map[string]stubResponse
+		exp      Space
+	}{
+		{
+			map[string]string{"AVBL": ""},
+			map[string]stubResponse{"AVBL pub": {213, "1234567"}},
+			Space{Available: 1234567, Limit: -1, Used: -1, Source: "AVBL"},
+		},
+		{
+			map[string]string{},
+			map[string]stubResponse{"SITE QUOTA": {200, quotaReply}},
+			Space{Available: 768, Limit: 1024, Used: 256, Source: "SITE QUOTA"},
+		},
+		{
+			map[string]string{"AVBL": ""},
+			map[string]stubResponse{
+				"AVBL pub":    {550, "Not a directory"},
+				"SITE QUOTA":  {200, unlimitedReply},
+				"SITE DF pub": {200, dfReply},
+			},
+			Space{Available: 29784192 * 1024, Limit: 41152736 * 1024, Used: 9254836 * 1024, Source: "SITE DF"},
+		},
+		{
+			map[string]string{},
+			map[string]stubResponse{
+				"SITE QUOTA":  {200, unlimitedReply},
+				"SITE DF pub": {500, "Unknown command"},
+			},
+			Space{Available: -1, Limit: -1, Used: -1, Source: "SITE QUOTA"},
+		},
+	}
+
+	for _, c := range cases {
+		client := newClient(Config{stubResponses: c.stubs}, nil)
+		pconn := &persistentConn{config: client.config, features: c.features}
+
+		space, err := client.available(pconn, "pub")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if space != c.exp {
+			t.Errorf("exp %+v, got %+v", c.exp, space)
+		}
+	}
+
+	client := newClient(Config{stubResponses: map[string]stubResponse{
+		"SITE QUOTA":  {500, "Unknown command"},
+		"SITE DF pub": {500, "Unknown command"},
+	}}, nil)
+	pconn := &persistentConn{config: client.config, features: map[string]string{}}
+
+	if _, err := client.available(pconn, "pub"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -43,7 +43,6 @@ AllowStoreRestart on
 AllowOverwrite on
 UseReverseDNS off
 RequireValidShell off
-TLSOptions NoSessionReuseRequired
 <IfModule mod_tls.c>
   TLSEngine on
   TLSRSACertificateFile $ftpd_dir/server.cert
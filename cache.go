@@ -0,0 +1,186 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataCache remembers Stat and ReadDir results for a limited time. See
+// Config.MetadataCacheTTL.
+type metadataCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	files map[string]cachedFile
+	dirs  map[string]cachedDir
+}
+
+type cachedFile struct {
+	info    os.FileInfo
+	expires time.Time
+}
+
+type cachedDir struct {
+	infos   []os.FileInfo
+	expires time.Time
+}
+
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{
+		ttl:   ttl,
+		files: make(map[string]cachedFile),
+		dirs:  make(map[string]cachedDir),
+	}
+}
+
+// Normalize a path so equivalent spellings share an entry. Relative and
+// absolute paths to the same file are still cached separately.
+func cacheKey(p string) string {
+	if p == "" {
+		return "."
+	}
+	return path.Clean(p)
+}
+
+func (mc *metadataCache) stat(p string) (os.FileInfo, bool) {
+	if mc == nil {
+		return nil, false
+	}
+
+	key := cacheKey(p)
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, found := mc.files[key]
+	if !found {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(mc.files, key)
+		return nil, false
+	}
+
+	return entry.info, true
+}
+
+func (mc *metadataCache) readDir(p string) ([]os.FileInfo, bool) {
+	if mc == nil {
+		return nil, false
+	}
+
+	key := cacheKey(p)
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, found := mc.dirs[key]
+	if !found {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(mc.dirs, key)
+		return nil, false
+	}
+
+	// copy so callers can't modify our entry
+	return append([]os.FileInfo(nil), entry.infos...), true
+}
+
+func (mc *metadataCache) putStat(p string, info os.FileInfo) {
+	if mc == nil {
+		return
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.files[cacheKey(p)] = cachedFile{
+		info:    info,
+		expires: time.Now().Add(mc.ttl),
+	}
+}
+
+// Remember a directory listing, including each entry for later Stat calls.
+func (mc *metadataCache) putReadDir(p string, infos []os.FileInfo, includesListing bool) {
+	if mc == nil {
+		return
+	}
+
+	key := cacheKey(p)
+	expires := time.Now().Add(mc.ttl)
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if includesListing {
+		mc.dirs[key] = cachedDir{
+			infos:   append([]os.FileInfo(nil), infos...),
+			expires: expires,
+		}
+	}
+
+	for _, info := range infos {
+		mc.files[path.Join(key, info.Name())] = cachedFile{
+			info:    info,
+			expires: expires,
+		}
+	}
+}
+
+// Forget everything about "p", its parent's listing and anything below it.
+func (mc *metadataCache) invalidate(p string) {
+	if mc == nil {
+		return
+	}
+
+	key := cacheKey(p)
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	below := func(k string) bool {
+		if key == "." {
+			return !path.IsAbs(k)
+		}
+		return strings.HasPrefix(k, prefix)
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	delete(mc.dirs, path.Dir(key))
+	delete(mc.dirs, key)
+	delete(mc.files, key)
+
+	for k := range mc.dirs {
+		if below(k) {
+			delete(mc.dirs, k)
+		}
+	}
+
+	for k := range mc.files {
+		if below(k) {
+			delete(mc.files, k)
+		}
+	}
+}
+
+// Forget everything.
+func (mc *metadataCache) clear() {
+	if mc == nil {
+		return
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.files = make(map[string]cachedFile)
+	mc.dirs = make(map[string]cachedDir)
+}
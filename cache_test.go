@@ -0,0 +1,133 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMetadataCache(t *testing.T) {
+	mc := newMetadataCache(time.Minute)
+
+	listing := []os.FileInfo{
+		&ftpFile{name: "a", mode: os.ModeDir},
+		&ftpFile{name: "b"},
+	}
+	mc.putReadDir("/pub/", listing, true)
+
+	if infos, found := mc.readDir("/pub"); !found || len(infos) != 2 {
+		t.Fatalf("expected cached listing, got %v (%v)", infos, found)
+	}
+
+	if info, found := mc.stat("/pub/b"); !found || info.Name() != "b" {
+		t.Errorf("expected cached stat, got %v (%v)", info, found)
+	}
+
+	mc.putReadDir("/pub/a", []os.FileInfo{&ftpFile{name: "c"}}, true)
+
+	// removing "a" clears it, its contents and its parent's listing
+	mc.invalidate("/pub/a")
+
+	if _, found := mc.readDir("/pub"); found {
+		t.Error("parent listing should be invalidated")
+	}
+
+	if _, found := mc.readDir("/pub/a"); found {
+		t.Error("listing should be invalidated")
+	}
+
+	if _, found := mc.stat("/pub/a/c"); found {
+		t.Error("child should be invalidated")
+	}
+
+	if _, found := mc.stat("/pub/b"); !found {
+		t.Error("sibling should still be cached")
+	}
+
+	mc.putStat("", &ftpFile{name: "root"})
+	if _, found := mc.stat("."); !found {
+		t.Error(`"" and "." should share an entry`)
+	}
+
+	mc.clear()
+	if _, found := mc.stat("/pub/b"); found {
+		t.Error("cache should be empty")
+	}
+
+	mc = newMetadataCache(time.Nanosecond)
+	mc.putStat("x", &ftpFile{name: "x"})
+	time.Sleep(time.Millisecond)
+	if _, found := mc.stat("x"); found {
+		t.Error("entry should have expired")
+	}
+
+	// disabled cache
+	mc = nil
+	mc.putStat("x", &ftpFile{name: "x"})
+	if _, found := mc.stat("x"); found {
+		t.Error("nil cache shouldn't cache")
+	}
+	mc.invalidate("x")
+}
+
+func TestMetadataCacheInvalidation(t *testing.T) {
+	for _, addr := range ftpdAddrs {
+		config := goftpConfig
+		config.MetadataCacheTTL = time.Minute
+
+		c, err := DialConfig(config, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := c.ReadDir("subdir")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := c.Stat("subdir/1234.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(list) != 1 || info != list[0] {
+			t.Error("Stat should have been served from ReadDir's results")
+		}
+
+		os.Remove("testroot/git-ignored/foo")
+
+		if _, err := c.Stat("git-ignored/foo"); err == nil {
+			t.Fatal("file shouldn't exist yet")
+		}
+
+		if _, err := c.ReadDir("git-ignored"); err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Store("git-ignored/foo", bytes.NewReader([]byte{1, 2, 3, 4}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Stat("git-ignored/foo"); err != nil {
+			t.Errorf("Store should have invalidated the cache: %s", err)
+		}
+
+		if err := c.Delete("git-ignored/foo"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Stat("git-ignored/foo"); err == nil {
+			t.Error("Delete should have invalidated the cache")
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
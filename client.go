@@ -9,7 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
+	"strings"
 	"sync"
 	"time"
 )
@@ -31,6 +33,25 @@ type Error interface {
 	// Similarly, this will return the text response from the server, or empty
 	// string.
 	Message() string
+
+	// The verb of the command the error relates to (e.g. "RETR"), or empty
+	// string if it didn't happen while running a command.
+	Command() string
+
+	// The command's argument, usually a path, or empty string. Passwords are
+	// redacted.
+	Argument() string
+
+	// The "host:port" of the server the error came from, or empty string.
+	Host() string
+
+	// The index of the connection the error happened on, as shown in debug
+	// logging, or 0.
+	ConnIndex() int
+
+	// What kind of failure this is. This is finer grained than Temporary,
+	// e.g. to retry data connection failures but not quota errors.
+	Kind() ErrorKind
 }
 
 type ftpError struct {
@@ -39,14 +60,43 @@ type ftpError struct {
 	msg       string
 	timeout   bool
 	temporary bool
+
+	// failed establishing a data connection
+	dataConn bool
+
+	// set where the failure site determines the kind, e.g. parse errors
+	kind ErrorKind
+
+	// where the error happened (see persistentConn.withContext)
+	cmd     string
+	arg     string
+	host    string
+	connIdx int
 }
 
 func (e ftpError) Error() string {
+	var prefix string
+	if e.host != "" {
+		prefix = fmt.Sprintf("#%d %s ", e.connIdx, e.host)
+		if e.cmd != "" {
+			prefix += strings.TrimSpace(e.cmd+" "+e.arg) + ": "
+		}
+	}
+
 	if e.code != 0 {
-		return fmt.Sprintf("unexpected response: %d-%s", e.code, e.msg)
+		return fmt.Sprintf("%sunexpected response: %d-%s", prefix, e.code, e.msg)
 	} else {
-		return e.err.Error()
+		return prefix + e.err.Error()
+	}
+}
+
+// The Error "e" wraps, if any.
+func (e ftpError) wrapped() (Error, bool) {
+	var fe Error
+	if e.err != nil && errors.As(e.err, &fe) {
+		return fe, true
 	}
+	return nil, false
 }
 
 func (e ftpError) Temporary() bool {
@@ -58,19 +108,235 @@ func (e ftpError) Timeout() bool {
 }
 
 func (e ftpError) Code() int {
-	if fe, ok := e.err.(Error); ok {
+	if fe, ok := e.wrapped(); ok {
 		return fe.Code()
 	}
 	return e.code
 }
 
 func (e ftpError) Message() string {
-	if fe, ok := e.err.(Error); ok {
+	if fe, ok := e.wrapped(); ok {
 		return fe.Message()
 	}
 	return e.msg
 }
 
+func (e ftpError) Command() string {
+	if fe, ok := e.wrapped(); ok && e.host == "" {
+		return fe.Command()
+	}
+	return e.cmd
+}
+
+func (e ftpError) Argument() string {
+	if fe, ok := e.wrapped(); ok && e.host == "" {
+		return fe.Argument()
+	}
+	return e.arg
+}
+
+func (e ftpError) Host() string {
+	if fe, ok := e.wrapped(); ok && e.host == "" {
+		return fe.Host()
+	}
+	return e.host
+}
+
+func (e ftpError) ConnIndex() int {
+	if fe, ok := e.wrapped(); ok && e.host == "" {
+		return fe.ConnIndex()
+	}
+	return e.connIdx
+}
+
+func (e ftpError) Kind() ErrorKind {
+	switch {
+	case e.timeout:
+		return KindTimeout
+	case e.kind != KindOther:
+		return e.kind
+	case e.dataConn:
+		return KindDataConn
+	case e.code != 0:
+		return replyKind(e.code, e.msg)
+	}
+
+	if fe, ok := e.wrapped(); ok {
+		return fe.Kind()
+	}
+
+	return KindOther
+}
+
+// Unwrap returns the underlying error, if any, so errors.Is and errors.As see
+// through ftpError.
+func (e ftpError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the server's reply means "target", which may be
+// fs.ErrNotExist, fs.ErrPermission, fs.ErrExist or ErrNotSupported. FTP
+// servers use 550 for most failures, so this relies on the reply message.
+func (e ftpError) Is(target error) bool {
+	return e.code != 0 && classifyReply(e.code, e.msg) == target
+}
+
+var (
+	// ErrClosed is wrapped by errors from a closed Client or Session.
+	ErrClosed = errors.New("closed")
+
+	// ErrNotSupported is wrapped by errors for operations the server doesn't
+	// support. Errors for replies saying a command isn't implemented match it
+	// too (with errors.Is).
+	ErrNotSupported = errors.New("not supported by server")
+)
+
+// Phrases servers use in reply messages, checked in order. "not exist" comes
+// before "exists" since it contains it.
+var replyPhrases = []struct {
+	phrase string
+	err    error
+}{
+	{"no such file", fs.ErrNotExist},
+	{"no such directory", fs.ErrNotExist},
+	{"not found", fs.ErrNotExist},
+	{"not exist", fs.ErrNotExist},
+	{"n't exist", fs.ErrNotExist},
+	{"cannot find", fs.ErrNotExist},
+	{"can't find", fs.ErrNotExist},
+	{"permission denied", fs.ErrPermission},
+	{"access denied", fs.ErrPermission},
+	{"access is denied", fs.ErrPermission},
+	{"not allowed", fs.ErrPermission},
+	{"forbidden", fs.ErrPermission},
+	{"insufficient privilege", fs.ErrPermission},
+	{"operation not permitted", fs.ErrPermission},
+	{"read-only", fs.ErrPermission},
+	{"already exist", fs.ErrExist},
+	{"file exists", fs.ErrExist},
+	{"directory exists", fs.ErrExist},
+}
+
+// Map a negative reply to the fs or goftp error it means, or nil.
+func classifyReply(code int, msg string) error {
+	switch code {
+	case replyCommandSyntaxError, replyCommandNotImplemented, replyCommandNotImplementedForParameter:
+		return ErrNotSupported
+	case replyNotLoggedIn, replyNeedAccountToStore:
+		return fs.ErrPermission
+	}
+
+	if code/100 != 4 && code/100 != 5 {
+		return nil
+	}
+
+	msg = strings.ToLower(msg)
+	for _, p := range replyPhrases {
+		if strings.Contains(msg, p.phrase) {
+			return p.err
+		}
+	}
+
+	return nil
+}
+
+// ErrorKind classifies errors. See Error.Kind.
+type ErrorKind int
+
+const (
+	// KindOther is for errors that fit none of the other kinds.
+	KindOther ErrorKind = iota
+
+	// KindAuth means the server didn't accept the login ("530", "332" or
+	// "532" replies).
+	KindAuth
+
+	// KindNotFound means the file or directory doesn't exist.
+	KindNotFound
+
+	// KindPermission means the user isn't allowed to perform the operation.
+	KindPermission
+
+	// KindStorage means the server is out of space or the user's quota is
+	// exceeded ("452" or "552" replies).
+	KindStorage
+
+	// KindDataConn means a data connection couldn't be established or was
+	// closed prematurely ("425" or "426" replies, or network errors).
+	KindDataConn
+
+	// KindProtocol means the server's reply didn't make sense: an unexpected
+	// reply code, a malformed reply, or a rejected command ("500" to "504"
+	// replies).
+	KindProtocol
+
+	// KindTimeout means a network operation timed out.
+	KindTimeout
+
+	// KindShutdown means the server is closing the connection ("421" reply).
+	KindShutdown
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindAuth:
+		return "auth"
+	case KindNotFound:
+		return "not found"
+	case KindPermission:
+		return "permission"
+	case KindStorage:
+		return "storage"
+	case KindDataConn:
+		return "data connection"
+	case KindProtocol:
+		return "protocol"
+	case KindTimeout:
+		return "timeout"
+	case KindShutdown:
+		return "shutdown"
+	default:
+		return "other"
+	}
+}
+
+// Map an unexpected reply to its ErrorKind.
+func replyKind(code int, msg string) ErrorKind {
+	switch code {
+	case replyServiceNotAvailable:
+		return KindShutdown
+	case replyCantOpenDataConnection, replyConnectionClosed:
+		return KindDataConn
+	case replyNotLoggedIn, replyNeedAccount, replyNeedAccountToStore:
+		return KindAuth
+	case replyOutOfSpace, replyExceededStorageAllocation:
+		return KindStorage
+	case replyCommandSyntaxError, replyParameterSyntaxError, replyCommandNotImplemented,
+		replyBadCommandSequence, replyCommandNotImplementedForParameter:
+		return KindProtocol
+	}
+
+	switch classifyReply(code, msg) {
+	case fs.ErrNotExist:
+		return KindNotFound
+	case fs.ErrPermission:
+		return KindPermission
+	}
+
+	// a positive reply, just not the expected one
+	if code/100 < 4 {
+		return KindProtocol
+	}
+
+	return KindOther
+}
+
+// Whether "err" is (or wraps) a network timeout.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
 // TLSMode represents the FTPS connection strategy. Servers cannot support
 // both modes on the same port.
 type TLSMode int
@@ -85,6 +351,70 @@ const (
 	TLSImplicit TLSMode = 1
 )
 
+// TLSPolicy controls whether a connection must, may or must not use TLS
+// when TLSConfig is set.
+type TLSPolicy int
+
+const (
+	// TLSRequire means connections fail if TLS can't be negotiated.
+	TLSRequire TLSPolicy = 0
+
+	// TLSPrefer means connections try "AUTH TLS", but continue in plaintext
+	// if the server doesn't support it (replies 500, 502 or 504). Other
+	// failures still fail the connection. It is the same as TLSRequire with
+	// TLSImplicit.
+	TLSPrefer TLSPolicy = 1
+
+	// TLSNever means connections don't use TLS even though TLSConfig is set.
+	TLSNever TLSPolicy = 2
+)
+
+// DataProtection represents the protection level of data connections when
+// using TLS, negotiated with the "PROT" command.
+type DataProtection int
+
+const (
+	// DataProtectionPrivate means data connections use TLS ("PROT P").
+	DataProtectionPrivate DataProtection = 0
+
+	// DataProtectionClear means data connections are plaintext ("PROT C"),
+	// while the control connection still uses TLS.
+	DataProtectionClear DataProtection = 1
+)
+
+func (p DataProtection) String() string {
+	switch p {
+	case DataProtectionPrivate:
+		return "P"
+	case DataProtectionClear:
+		return "C"
+	default:
+		return fmt.Sprintf("DataProtection(%d)", int(p))
+	}
+}
+
+// StatListMode controls when directory listings are fetched with "STAT" over
+// the control connection instead of "MLSD"/"LIST" over a data connection.
+// This is useful when firewalls make data connections unreliable. "STAT"
+// listings are in "LIST" format, so ServerLocation applies.
+type StatListMode int
+
+const (
+	// StatListNever means always use a data connection for listings.
+	StatListNever StatListMode = 0
+
+	// StatListFallback means use "STAT" if a data connection can't be
+	// established for a listing.
+	StatListFallback StatListMode = 1
+
+	// StatListAlways means always use "STAT" for listings.
+	StatListAlways StatListMode = 2
+)
+
+// DefaultMLSTFacts are the facts requested from servers supporting "MLST"
+// unless Config.MLSTFacts says otherwise.
+var DefaultMLSTFacts = []string{"type", "size", "modify", "perm", "unique", "UNIX.mode", "create"}
+
 // for testing
 type stubResponse struct {
 	code int
@@ -109,8 +439,15 @@ type Config struct {
 	// of data transfers. Defaults to 5 seconds.
 	Timeout time.Duration
 
-	// TLS Config used for FTPS. If provided, it will be an error if the server
-	// does not support TLS. Both the control and data connection will use TLS.
+	// TLS Config used for FTPS. If provided, TLSPolicy decides whether
+	// connections use TLS; by default it is an error if the server does not
+	// support TLS. Once logged in over TLS, the control connection stays
+	// encrypted unless ClearControlChannel is set, and data connections are
+	// encrypted unless DataProtection is DataProtectionClear. Data connections
+	// resume the control connection's TLS session, as many servers require, so
+	// each connection uses its own copy of TLSConfig with ClientSessionCache
+	// replaced. If ServerName is empty, each copy verifies certificates against
+	// the hostname its address was resolved from.
 	TLSConfig *tls.Config
 
 	// FTPS mode. TLSExplicit means connect non-TLS, then upgrade connection to
@@ -118,6 +455,40 @@ type Config struct {
 	// TLS. Defaults to TLSExplicit.
 	TLSMode TLSMode
 
+	// Whether TLS is required, attempted or skipped when TLSConfig is set.
+	// Use Session.TLSNegotiated or RawConn.TLSNegotiated to check whether a
+	// connection actually uses TLS. Defaults to TLSRequire.
+	TLSPolicy TLSPolicy
+
+	// Send "CCC" after logging in with TLSExplicit to switch the control
+	// connection back to plaintext. Data connections stay encrypted. This lets
+	// NAT-aware firewalls see "PASV"/"PORT" replies on the control connection.
+	// The login itself is still encrypted. Defaults to false.
+	ClearControlChannel bool
+
+	// Protection level of data connections when using TLS.
+	// DataProtectionClear skips the TLS overhead on transfers while keeping
+	// the login encrypted. Combine DataProtectionPrivate with
+	// ClearControlChannel to only encrypt data connections. Defaults to
+	// DataProtectionPrivate.
+	DataProtection DataProtection
+
+	// SHA-256 fingerprints (see SPKIFingerprint) of the public keys each host
+	// may present, keyed by hostname or IP as passed to Dial. Connections to a
+	// host with pins fail unless its certificate matches one of them. This
+	// also applies to data connections. Pinning alone doesn't skip normal
+	// certificate verification; set InsecureSkipVerify in TLSConfig for
+	// self-signed certificates. Defaults to no pins.
+	TLSPins map[string][]string
+
+	// Path of a known hosts file for trust on first use. The first
+	// connection to a host records its certificate's fingerprint in the file,
+	// and later connections fail if the host presents a different one. Hosts
+	// on ports other than 21 are recorded as "[host]:port". Like TLSPins, this
+	// doesn't skip normal certificate verification. Defaults to no known hosts
+	// file.
+	TLSKnownHostsFile string
+
 	// This flag controls whether to use IPv6 addresses found when resolving
 	// hostnames. Defaults to false to prevent failures when your computer can't
 	// IPv6. If the hostname(s) only resolve to IPv6 addresses, Dial() will still
@@ -133,6 +504,27 @@ type Config struct {
 	// server does not support "MLST"/"MLSD". Defaults to UTC.
 	ServerLocation *time.Location
 
+	// Detect the server's time zone instead of relying on ServerLocation for
+	// servers that support "MDTM" but not "MLST"/"MLSD". The first "LIST"
+	// based ReadDir or Stat on each host compares a file's listed mtime with
+	// its "MDTM" reply, which is always in UTC, and uses the derived offset
+	// for that host from then on. ServerLocation applies until detection
+	// succeeds, and for good if the first 3 listings on a host don't reveal
+	// its offset (e.g. they only have year-dated entries). While detection is
+	// pending, listings are buffered, so ReadDirFunc doesn't stream them. The
+	// detected offset is fixed, so it doesn't follow daylight saving time
+	// changes.
+	DetectServerLocation bool
+
+	// Custom parsers for "LIST" output, tried in order before the built-in
+	// formats (Unix "ls -l", DOS, EPLF, NetWare, OS/400, VMS and MVS). Use
+	// this to override how the built-in formats are parsed. See ListParser.
+	ListParsers []ListParser
+
+	// Custom parsers for "LIST" output, tried in order after the built-in
+	// formats when none of them recognize an entry. See ListParser.
+	FallbackListParsers []ListParser
+
 	// Enable "active" FTP data connections where the server connects to the client to
 	// establish data connections (does not work if client is behind NAT). If TLSConfig
 	// is specified, it will be used when listening for active connections.
@@ -149,6 +541,35 @@ type Config struct {
 	// hung connections.
 	DisableEPSV bool
 
+	// If positive, Stat and ReadDir results are cached for this long. Entries
+	// listed by ReadDir are cached too, so subsequent Stat calls on them don't
+	// hit the server. Store, Delete, Rename, Mkdir and Rmdir invalidate the
+	// affected paths. Changes made by other clients won't be noticed until
+	// entries expire. Paths are cached as given, so "/pub/a" and "a" (relative
+	// to "/pub") are distinct entries. Defaults to 0 (no caching).
+	MetadataCacheTTL time.Duration
+
+	// Resolve symlinks returned by ReadDir/ReadDirAll to discover whether they
+	// point to directories. Resolved links keep os.ModeSymlink and also get
+	// os.ModeDir if their target is a directory, so IsDir() reports true for
+	// them. This costs extra round trips per symlink. Links that can't be
+	// resolved (e.g. dangling links or loops) are returned unchanged, as are
+	// links to the listed directory or one of its ancestors, so walkers that
+	// recurse into IsDir() entries don't loop on them. Cycles through several
+	// links (e.g. "a/x -> ../b" and "b/y -> ../a") aren't detected, so such
+	// walkers can still recurse forever. FS still reports links as symlinks,
+	// so fs.WalkDir doesn't follow them.
+	FollowSymlinks bool
+
+	// Facts to enable with "OPTS MLST" if the server supports "MLST". Facts
+	// the server doesn't offer are ignored. "type", "size" and "modify" are
+	// needed to fill in os.FileInfo's. Defaults to DefaultMLSTFacts.
+	MLSTFacts []string
+
+	// Controls whether ReadDir and Stat fetch "LIST" output with "STAT" over
+	// the control connection. Defaults to StatListNever.
+	StatListMode StatListMode
+
 	// For testing convenience.
 	stubResponses map[string]stubResponse
 }
@@ -159,20 +580,32 @@ type Config struct {
 // per host, methods will block waiting for a free connection.
 type Client struct {
 	config          Config
-	hosts           []string
+	hosts           []hostAddr
 	freeConnCh      chan *persistentConn
-	numConnsPerHost map[string]int
+	numConnsPerHost map[hostAddr]int
 	allCons         map[int]*persistentConn
 	connIdx         int
 	rawConnIdx      int
 	mu              sync.Mutex
 	t0              time.Time
 	closed          bool
+
+	// if set, the client's only connection (see Session)
+	pinned *persistentConn
+
+	// nil if caching is disabled
+	cache *metadataCache
+
+	// time zones detected per host
+	locations *serverLocations
+
+	// nil if TLSKnownHostsFile isn't set
+	knownHosts *knownHosts
 }
 
 // Construct and return a new client Conn, setting default config
 // values as necessary.
-func newClient(config Config, hosts []string) *Client {
+func newClient(config Config, hosts []hostAddr) *Client {
 
 	if config.ConnectionsPerHost <= 0 {
 		config.ConnectionsPerHost = 5
@@ -194,17 +627,34 @@ func newClient(config Config, hosts []string) *Client {
 		config.ServerLocation = time.UTC
 	}
 
+	if config.MLSTFacts == nil {
+		config.MLSTFacts = DefaultMLSTFacts
+	}
+
 	if config.ActiveListenAddr == "" {
 		config.ActiveListenAddr = ":0"
 	}
 
+	var cache *metadataCache
+	if config.MetadataCacheTTL > 0 {
+		cache = newMetadataCache(config.MetadataCacheTTL)
+	}
+
+	var knownHosts *knownHosts
+	if config.TLSKnownHostsFile != "" {
+		knownHosts = newKnownHosts(config.TLSKnownHostsFile)
+	}
+
 	return &Client{
 		config:          config,
 		freeConnCh:      make(chan *persistentConn, len(hosts)*config.ConnectionsPerHost),
 		t0:              time.Now(),
 		hosts:           hosts,
 		allCons:         make(map[int]*persistentConn),
-		numConnsPerHost: make(map[string]int),
+		numConnsPerHost: make(map[hostAddr]int),
+		cache:           cache,
+		locations:       newServerLocations(),
+		knownHosts:      knownHosts,
 	}
 }
 
@@ -215,7 +665,7 @@ func (c *Client) Close() error {
 	c.mu.Lock()
 	if c.closed {
 		c.mu.Unlock()
-		return ftpError{err: errors.New("already closed")}
+		return ftpError{err: fmt.Errorf("already %w", ErrClosed)}
 	}
 	c.closed = true
 
@@ -255,6 +705,9 @@ func (c *Client) numOpenConns() int {
 
 // Get an idle connection.
 func (c *Client) getIdleConn() (*persistentConn, error) {
+	if c.pinned != nil {
+		return c.getPinnedConn()
+	}
 
 	// First check for available connections in the channel.
 Loop:
@@ -264,7 +717,7 @@ Loop:
 			if pconn.broken {
 				c.debug("#%d was ready (broken)", pconn.idx)
 				c.mu.Lock()
-				c.numConnsPerHost[pconn.host]--
+				c.numConnsPerHost[pconn.hostAddr()]--
 				c.mu.Unlock()
 				c.removeConn(pconn)
 			} else {
@@ -281,13 +734,18 @@ Loop:
 	for {
 		c.mu.Lock()
 
+		if c.closed {
+			c.mu.Unlock()
+			return nil, ftpError{err: fmt.Errorf("client %w", ErrClosed)}
+		}
+
 		// can we open a connection to some host
 		if c.numOpenConns() < len(c.hosts)*c.config.ConnectionsPerHost {
 			c.connIdx++
 			idx := c.connIdx
 
 			// find the next host with less than ConnectionsPerHost connections
-			var host string
+			var host hostAddr
 			for i := idx; i < idx+len(c.hosts); i++ {
 				if c.numConnsPerHost[c.hosts[i%len(c.hosts)]] < c.config.ConnectionsPerHost {
 					host = c.hosts[i%len(c.hosts)]
@@ -295,7 +753,7 @@ Loop:
 				}
 			}
 
-			if host == "" {
+			if host == (hostAddr{}) {
 				panic("this shouldn't be possible")
 			}
 
@@ -319,7 +777,7 @@ Loop:
 		if pconn.broken {
 			c.debug("waited and got #%d (broken)", pconn.idx)
 			c.mu.Lock()
-			c.numConnsPerHost[pconn.host]--
+			c.numConnsPerHost[pconn.hostAddr()]--
 			c.mu.Unlock()
 			c.removeConn(pconn)
 		} else {
@@ -354,28 +812,36 @@ func (c *Client) OpenRawConn() (RawConn, error) {
 }
 
 // Open and set up a control connection.
-func (c *Client) openConn(idx int, host string) (pconn *persistentConn, err error) {
+func (c *Client) openConn(idx int, host hostAddr) (pconn *persistentConn, err error) {
 	pconn = &persistentConn{
 		idx:              idx,
 		features:         make(map[string]string),
 		config:           c.config,
 		t0:               c.t0,
 		currentType:      "A",
-		host:             host,
+		host:             host.addr,
+		serverName:       host.serverName,
 		epsvNotSupported: c.config.DisableEPSV,
 	}
 
+	if pconn.config.TLSConfig != nil {
+		pconn.config.TLSConfig = connTLSConfig(pconn.config.TLSConfig, pconn.serverName)
+		pconn.pinCertificates(c.knownHosts)
+	}
+
 	var conn net.Conn
 
-	if c.config.TLSConfig != nil && c.config.TLSMode == TLSImplicit {
-		pconn.debug("opening TLS control connection to %s", host)
+	useTLS := c.config.TLSConfig != nil && c.config.TLSPolicy != TLSNever
+
+	if useTLS && c.config.TLSMode == TLSImplicit {
+		pconn.debug("opening TLS control connection to %s", host.addr)
 		dialer := &net.Dialer{
 			Timeout: c.config.Timeout,
 		}
-		conn, err = tls.DialWithDialer(dialer, "tcp", host, pconn.config.TLSConfig)
+		conn, err = tls.DialWithDialer(dialer, "tcp", host.addr, pconn.config.TLSConfig)
 	} else {
-		pconn.debug("opening control connection to %s", host)
-		conn, err = net.DialTimeout("tcp", host, c.config.Timeout)
+		pconn.debug("opening control connection to %s", host.addr)
+		conn, err = net.DialTimeout("tcp", host.addr, c.config.Timeout)
 	}
 
 	var (
@@ -391,6 +857,8 @@ func (c *Client) openConn(idx int, host string) (pconn *persistentConn, err erro
 		err = ftpError{
 			err:       err,
 			temporary: isTemporary,
+			timeout:   isTimeout(err),
+			kind:      tlsErrorKind(err),
 		}
 		goto Error
 	}
@@ -403,14 +871,21 @@ func (c *Client) openConn(idx int, host string) (pconn *persistentConn, err erro
 	}
 
 	if code != replyServiceReady {
-		err = ftpError{code: code, msg: msg}
+		err = pconn.replyError(code, msg)
 		goto Error
 	}
 
-	if c.config.TLSConfig != nil && c.config.TLSMode == TLSExplicit {
+	if useTLS && c.config.TLSMode == TLSExplicit {
 		err = pconn.logInTLS()
 	} else {
+		pconn.tlsNegotiated = useTLS
+
 		err = pconn.logIn()
+
+		// implicit TLS servers default to "PROT P"
+		if err == nil && useTLS && c.config.DataProtection != DataProtectionPrivate {
+			err = pconn.setDataProtection()
+		}
 	}
 
 	if err != nil {
@@ -421,11 +896,19 @@ func (c *Client) openConn(idx int, host string) (pconn *persistentConn, err erro
 		goto Error
 	}
 
+	if err = pconn.negotiateMLST(); err != nil {
+		goto Error
+	}
+
+	if err = pconn.fetchSystem(); err != nil {
+		goto Error
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.closed {
-		err = ftpError{err: errors.New("client closed")}
+		err = ftpError{err: fmt.Errorf("client %w", ErrClosed)}
 		goto Error
 	}
 
@@ -436,5 +919,5 @@ func (c *Client) openConn(idx int, host string) (pconn *persistentConn, err erro
 
 Error:
 	pconn.close()
-	return nil, err
+	return nil, pconn.addContext(err)
 }
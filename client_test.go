@@ -7,7 +7,14 @@ package goftp
 import (
 	"bytes"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -38,6 +45,32 @@ func TestTimeoutConnect(t *testing.T) {
 	}
 }
 
+func TestDialErrorContext(t *testing.T) {
+	// find a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	c, err := DialConfig(Config{Timeout: time.Second}, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.ReadDir("")
+
+	fe, ok := err.(Error)
+	if !ok {
+		t.Fatalf("got %T %v", err, err)
+	}
+
+	if fe.Host() != addr || fe.ConnIndex() != 1 || !strings.HasPrefix(err.Error(), "#1 "+addr+" ") {
+		t.Errorf("got %q (host %q, conn %d)", err, fe.Host(), fe.ConnIndex())
+	}
+}
+
 func TestExplicitTLS(t *testing.T) {
 	for _, addr := range ftpdAddrs {
 		config := Config{
@@ -148,3 +181,196 @@ func TestPooling(t *testing.T) {
 		t.Error("Leaked a connection")
 	}
 }
+
+func TestErrorIs(t *testing.T) {
+	cases := []struct {
+		err error
+		exp error
+	}{
+		{ftpError{code: 550, msg: "missing: No such file or directory"}, fs.ErrNotExist},
+		{ftpError{code: 550, msg: "The system cannot find the file specified."}, fs.ErrNotExist},
+		{ftpError{code: 550, msg: "Directory not found."}, fs.ErrNotExist},
+		{ftpError{code: 450, msg: "foo: does not exist"}, fs.ErrNotExist},
+		{ftpError{code: 550, msg: "secret: Permission denied"}, fs.ErrPermission},
+		{ftpError{code: 550, msg: "Access is denied."}, fs.ErrPermission},
+		{ftpError{code: 530, msg: "Not logged in."}, fs.ErrPermission},
+		{ftpError{code: 550, msg: "Can't create directory: File exists"}, fs.ErrExist},
+		{ftpError{code: 550, msg: "Directory already exists"}, fs.ErrExist},
+		{ftpError{code: 502, msg: "Command not implemented."}, ErrNotSupported},
+		{ftpError{code: 550, msg: "Requested action not taken."}, nil},
+		{ftpError{err: ftpError{code: 550, msg: "No such file or directory"}}, fs.ErrNotExist},
+		{&fs.PathError{Op: "open", Path: "foo", Err: ftpError{code: 550, msg: "No such file or directory"}}, fs.ErrNotExist},
+		{ftpError{err: fmt.Errorf("client %w", ErrClosed)}, ErrClosed},
+	}
+
+	all := []error{fs.ErrNotExist, fs.ErrPermission, fs.ErrExist, ErrNotSupported, ErrClosed}
+
+	for _, c := range cases {
+		for _, target := range all {
+			if got := errors.Is(c.err, target); got != (target == c.exp) {
+				t.Errorf("%s: errors.Is(%s) = %t", c.err, target, got)
+			}
+		}
+	}
+
+	c := newClient(Config{}, []hostAddr{{addr: "127.0.0.1:21"}})
+	c.Close()
+
+	if _, err := c.Stat("foo"); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v", err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorKind(t *testing.T) {
+	cases := []struct {
+		err Error
+		exp ErrorKind
+	}{
+		{ftpError{code: 530, msg: "Login incorrect."}, KindAuth},
+		{ftpError{code: 550, msg: "No such file or directory"}, KindNotFound},
+		{ftpError{code: 550, msg: "Permission denied"}, KindPermission},
+		{ftpError{code: 552, msg: "Quota exceeded"}, KindStorage},
+		{ftpError{code: 452, msg: "Insufficient storage space"}, KindStorage},
+		{ftpError{code: 425, msg: "Can't open data connection."}, KindDataConn},
+		{ftpError{code: 426, msg: "Connection closed; transfer aborted."}, KindDataConn},
+		{ftpError{code: 500, msg: "Unknown command."}, KindProtocol},
+		{ftpError{code: 200, msg: "OK"}, KindProtocol},
+		{ftpError{code: 421, msg: "Service not available, closing control connection."}, KindShutdown},
+		{ftpError{code: 550, msg: "Failed."}, KindOther},
+		{ftpError{err: timeoutError{}, timeout: isTimeout(timeoutError{})}, KindTimeout},
+		{ftpError{err: fmt.Errorf("foo"), kind: KindProtocol}, KindProtocol},
+		{ftpError{err: fmt.Errorf("%w (can't resume)", ftpError{code: 426, msg: "Aborted"})}, KindDataConn},
+		{ftpError{err: fmt.Errorf("client %w", ErrClosed)}, KindOther},
+	}
+
+	for _, c := range cases {
+		if got := c.err.Kind(); got != c.exp {
+			t.Errorf("%s: exp %s, got %s", c.err, c.exp, got)
+		}
+	}
+
+	if _, err := parseLIST("garbage", time.UTC, false); err.(Error).Kind() != KindProtocol {
+		t.Errorf("got %s", err.(Error).Kind())
+	}
+
+	// data connection that stalls or drops mid-transfer
+	for _, reset := range []bool{false, true} {
+		exp := KindTimeout
+		if reset {
+			exp = KindDataConn
+		}
+
+		err := retrieveFromBrokenDataConn(t, reset, io.Discard)
+		if fe, ok := err.(Error); !ok || fe.Kind() != exp || !fe.Temporary() || fe.Host() != "127.0.0.1:21" {
+			t.Errorf("reset=%v: got %v", reset, err)
+		}
+	}
+
+	// the caller's writer failing isn't the data connection's fault
+	errFull := errors.New("disk full")
+	err := retrieveFromBrokenDataConn(t, false, failingWriter{errFull})
+	if fe, ok := err.(Error); !ok || fe.Kind() != KindOther || fe.Temporary() || !errors.Is(err, errFull) {
+		t.Errorf("got %v", err)
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, w.err
+}
+
+// Serve control connections that only get a greeting, for clients whose
+// commands are all stubbed. Also returns the number of connections accepted
+// so far.
+func serveGreetings(t *testing.T) (string, *int32) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	accepted := new(int32)
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(accepted, 1)
+			conns = append(conns, conn)
+			conn.Write([]byte("220 ready\r\n"))
+		}
+	}()
+
+	return l.Addr().String(), accepted
+}
+
+// Retrieve a file into "dest" over a data connection that sends a few bytes
+// and then either stalls or is reset.
+func retrieveFromBrokenDataConn(t *testing.T, reset bool, dest io.Writer) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("partial"))
+
+		if reset {
+			// give the client's dial a chance to finish first
+			time.Sleep(20 * time.Millisecond)
+			conn.(*net.TCPConn).SetLinger(0)
+			return
+		}
+
+		<-done
+	}()
+
+	port := l.Addr().(*net.TCPAddr).Port
+
+	c := newClient(Config{
+		Timeout: 100 * time.Millisecond,
+		stubResponses: map[string]stubResponse{
+			"TYPE I":   {200, "Type set to I"},
+			"PASV":     {227, fmt.Sprintf("Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)},
+			"RETR foo": {150, "Opening BINARY mode data connection"},
+		},
+	}, []hostAddr{{addr: "127.0.0.1:21"}})
+
+	pconn := &persistentConn{
+		config:           c.config,
+		features:         map[string]string{},
+		host:             "127.0.0.1:21",
+		epsvNotSupported: true,
+	}
+	c.pinned = pconn
+	c.freeConnCh <- pconn
+
+	return c.Retrieve("foo", dest)
+}
@@ -0,0 +1,83 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"strings"
+	"time"
+)
+
+// FileFacts is returned by the Sys() method of the os.FileInfo's from
+// ReadDir, Stat and friends. It holds what the server reported about a file
+// beyond what fits in os.FileInfo. Facts the server didn't report are left
+// empty. Most fields are only populated from "MLST"/"MLSD" entries (see
+// RFC 3659 section 7).
+type FileFacts struct {
+	// The entry exactly as received from the server.
+	Raw string
+
+	// The "type" fact, e.g. "file", "dir", "cdir", "pdir" or
+	// "OS.unix=slink:<target>".
+	Type string
+
+	// The "unique" fact. Entries with the same (non-empty) Unique value refer
+	// to the same file, e.g. via different links or mount points.
+	Unique string
+
+	// The "perm" fact, listing which operations are permitted on the file.
+	// See HasPerm.
+	Perm string
+
+	// The "create" fact, or the zero time.
+	Create time.Time
+
+	// The "UNIX.owner" and "UNIX.group" facts, or the owner and group columns
+	// of "LIST" output. Depending on the server, these are either names or
+	// numeric IDs.
+	Owner string
+	Group string
+
+	// The number of hard links from "ls -l" style "LIST" output, or 0.
+	Nlink int
+
+	// The "UNIX.ownername" and "UNIX.groupname" facts.
+	OwnerName string
+	GroupName string
+
+	// The "UNIX.uid" and "UNIX.gid" facts.
+	UID string
+	GID string
+
+	// The "media-type", "charset" and "lang" facts.
+	MediaType string
+	Charset   string
+	Lang      string
+
+	// Any other facts, keyed by lowercase fact name.
+	Extra map[string]string
+}
+
+// HasPerm reports whether the "perm" fact grants permission "p". Per RFC 3659
+// section 7.5.5 the permissions are:
+//
+//	a: file may be appended to (APPE)
+//	c: files may be created in the directory (STOR)
+//	d: file or directory may be deleted (DELE/RMD)
+//	e: directory may be entered (CWD)
+//	f: file or directory may be renamed (RNFR)
+//	l: directory may be listed (LIST/NLST/MLSD)
+//	m: directories may be created in the directory (MKD)
+//	p: directory entries may be deleted
+//	r: file may be retrieved (RETR)
+//	w: file may be stored to (STOR)
+//
+// Since a missing "perm" fact says nothing about permissions, HasPerm
+// returns true if the server didn't send one.
+func (f *FileFacts) HasPerm(p byte) bool {
+	if f.Perm == "" {
+		return true
+	}
+	return strings.IndexByte(strings.ToLower(f.Perm), p) != -1
+}
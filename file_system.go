@@ -7,6 +7,7 @@ package goftp
 import (
 	"bufio"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -26,6 +27,7 @@ func (c *Client) Delete(path string) error {
 	}
 
 	defer c.returnConn(pconn)
+	defer c.cache.invalidate(path)
 
 	return pconn.sendCommandExpected(replyFileActionOkay, "DELE %s", path)
 }
@@ -38,6 +40,8 @@ func (c *Client) Rename(from, to string) error {
 	}
 
 	defer c.returnConn(pconn)
+	defer c.cache.invalidate(from)
+	defer c.cache.invalidate(to)
 
 	err = pconn.sendCommandExpected(replyFileActionPending, "RNFR %s", from)
 	if err != nil {
@@ -56,6 +60,7 @@ func (c *Client) Mkdir(path string) (string, error) {
 	}
 
 	defer c.returnConn(pconn)
+	defer c.cache.invalidate(path)
 
 	code, msg, err := pconn.sendCommand("MKD %s", path)
 	if err != nil {
@@ -63,12 +68,12 @@ func (c *Client) Mkdir(path string) (string, error) {
 	}
 
 	if code != replyDirCreated {
-		return "", ftpError{code: code, msg: msg}
+		return "", pconn.replyError(code, msg)
 	}
 
 	dir, err := extractDirName(msg)
 	if err != nil {
-		return "", err
+		return "", pconn.addContext(err)
 	}
 
 	return dir, nil
@@ -82,11 +87,15 @@ func (c *Client) Rmdir(path string) error {
 	}
 
 	defer c.returnConn(pconn)
+	defer c.cache.invalidate(path)
 
 	return pconn.sendCommandExpected(replyFileActionOkay, "RMD %s", path)
 }
 
-// Getwd returns the current working directory.
+// Getwd returns the current working directory. Since each call may run on
+// any of the pool's connections, this is effectively the directory the
+// server puts you in after login. Use a Session if you need to change
+// directories.
 func (c *Client) Getwd() (string, error) {
 	pconn, err := c.getIdleConn()
 	if err != nil {
@@ -95,21 +104,21 @@ func (c *Client) Getwd() (string, error) {
 
 	defer c.returnConn(pconn)
 
-	code, msg, err := pconn.sendCommand("PWD")
-	if err != nil {
-		return "", err
-	}
-
-	if code != replyDirCreated {
-		return "", ftpError{code: code, msg: msg}
-	}
+	return pconn.getwd()
+}
 
-	dir, err := extractDirName(msg)
+// System returns the server's reply to "SYST", e.g. "UNIX Type: L8" or
+// "Windows_NT". It is empty if the server doesn't support "SYST". The reply
+// selects which "LIST" format is tried first and whether "LIST -a" is used.
+func (c *Client) System() (string, error) {
+	pconn, err := c.getIdleConn()
 	if err != nil {
 		return "", err
 	}
 
-	return dir, nil
+	defer c.returnConn(pconn)
+
+	return pconn.system, nil
 }
 
 func commandNotSupporterdError(err error) bool {
@@ -123,7 +132,8 @@ func commandNotSupporterdError(err error) bool {
 // directories. The os.FileInfo's fields may be incomplete depending on what
 // the server supports. If the server does not support "MLSD", "LIST" will
 // be used. You may have to set ServerLocation in your config to get (more)
-// accurate ModTimes in this case.
+// accurate ModTimes in this case. Each os.FileInfo's Sys() method returns a
+// *FileFacts with any further details the server reported.
 func (c *Client) ReadDir(path string) ([]os.FileInfo, error) {
 	return c.readDir(false, path)
 }
@@ -136,58 +146,249 @@ func (c *Client) ReadDirAll(path string) ([]os.FileInfo, error) {
 }
 
 func (c *Client) readDir(all bool, path string) ([]os.FileInfo, error) {
+	if !all {
+		if infos, found := c.cache.readDir(path); found {
+			return infos, nil
+		}
+	}
+
 	pconn, err := c.getIdleConn()
 	if err != nil {
 		return nil, err
 	}
 	defer c.returnConn(pconn)
 
-	var (
-		entries []string
-		parser  = parseMLST
-	)
-	mlst := pconn.hasFeature("MLST")
+	var ret []os.FileInfo
+	err = c.listDir(pconn, all, path, func(info os.FileInfo) error {
+		ret = append(ret, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.FollowSymlinks {
+		c.resolveLinks(pconn, path, ret)
+	}
+
+	c.cache.putReadDir(path, ret, !all)
+
+	return ret, nil
+}
+
+// ReadDirFunc lists a directory like ReadDir, but calls "fn" for each entry
+// as it arrives from the server instead of buffering the whole listing, which
+// keeps memory usage flat for huge directories. If "fn" returns an error, the
+// listing is aborted and ReadDirFunc returns that error, except for
+// fs.SkipAll, which stops the listing without error. ReadDirFunc doesn't
+// consult the metadata cache and doesn't resolve symlinks (see
+// FollowSymlinks).
+func (c *Client) ReadDirFunc(path string, fn func(os.FileInfo) error) error {
+	pconn, err := c.getIdleConn()
+	if err != nil {
+		return err
+	}
+	defer c.returnConn(pconn)
+
+	err = c.listDir(pconn, false, path, fn)
+	if err == fs.SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+// Stream the parsed contents of directory "path" to "fn", using "MLSD" if
+// possible and "LIST" otherwise.
+func (c *Client) listDir(pconn *persistentConn, all bool, path string, fn func(os.FileInfo) error) error {
+	parser := parseMLST
+
+	handleEntry := func(entry string) error {
+		info, err := parser(entry, true)
+		if err != nil {
+			c.debug("error in ReadDir: %s", err)
+			return pconn.addContext(err)
+		}
+
+		if info == nil {
+			return nil
+		}
+
+		return fn(info)
+	}
+
+	listParsers := c.listParsers(pconn)
+	loc := c.serverLocation(pconn)
+	listParser := func(entry string, skipSelfParent bool) (os.FileInfo, error) {
+		return parseLISTWith(listParsers, entry, loc, skipSelfParent)
+	}
+
+	// MLSD always needs a data connection
+	mlst := pconn.hasFeature("MLST") && c.config.StatListMode != StatListAlways
 	if mlst {
-		entries, err = c.dataStringList(pconn, "MLSD %s", path)
+		err := c.dataStringFunc(pconn, handleEntry, "MLSD %s", path)
+		if err == nil {
+			return nil
+		}
+
+		fe, isFTPError := err.(ftpError)
+		switch {
+		case isFTPError && commandNotSupporterdError(err):
+		case isFTPError && fe.dataConn && c.config.StatListMode == StatListFallback:
+			pconn.debug("falling back to STAT listing: %s", err)
+			parser = listParser
+			return c.statListFunc(pconn, handleEntry, all, path)
+		default:
+			return err
+		}
+	}
+
+	parser = listParser
+
+	if c.needsLocation(pconn) {
+		entries, err := c.listDetectLocation(pconn, listParsers, all, path)
 		if err != nil {
-			if !commandNotSupporterdError(err) {
-				return nil, err
+			return err
+		}
+
+		loc = c.serverLocation(pconn)
+		for _, entry := range entries {
+			if err := handleEntry(entry); err != nil {
+				return err
 			}
-			mlst = false
 		}
+
+		return nil
 	}
-	if !mlst {
+
+	return c.listFunc(pconn, handleEntry, all, path)
+}
+
+// Run "LIST" on "path", calling "fn" with each line of the response. Depending
+// on Config.StatListMode, the listing may be fetched over the control
+// connection instead.
+func (c *Client) listFunc(pconn *persistentConn, fn func(string) error, all bool, path string) error {
+	if c.config.StatListMode != StatListAlways {
+		// non-Unix servers take "-a" as a path
 		var cmd string
-		if all {
+		if all && pconn.systemListFormat() == nil {
 			cmd = "LIST -a"
 		} else {
 			cmd = "LIST"
 		}
-		entries, err = c.dataStringList(pconn, "%s %s", cmd, path)
-		if err != nil {
-			return nil, err
+
+		err := c.dataStringFunc(pconn, fn, "%s %s", cmd, path)
+		fe, isFTPError := err.(ftpError)
+		if !isFTPError || !fe.dataConn || c.config.StatListMode != StatListFallback {
+			return err
 		}
-		parser = func(entry string, skipSelfParent bool) (os.FileInfo, error) {
-			return parseLIST(entry, c.config.ServerLocation, skipSelfParent)
+
+		pconn.debug("falling back to STAT listing: %s", err)
+	}
+
+	return c.statListFunc(pconn, fn, all, path)
+}
+
+// "STAT <path>" returns a LIST-style listing as a multi-line reply on the
+// control connection (RFC 959), so no data connection is needed:
+//
+//	213-Status of subdir:
+//	 -rw-r--r--   1 goftp    goftp           4 Jul 28 05:03 1234.bin
+//	213 End of status
+func (c *Client) statListFunc(pconn *persistentConn, fn func(string) error, all bool, path string) error {
+	var args []string
+	if pconn.systemListFormat() == nil {
+		if all {
+			args = append(args, "-la")
+		} else {
+			args = append(args, "-l")
 		}
 	}
 
-	var ret []os.FileInfo
-	for _, entry := range entries {
-		info, err := parser(entry, true)
-		if err != nil {
-			c.debug("error in ReadDir: %s", err)
-			return nil, err
+	if path != "" {
+		args = append(args, path)
+	} else if len(args) == 0 {
+		// a bare "STAT" returns the server's status instead
+		args = append(args, ".")
+	}
+
+	lines, err := c.controlStringList(pconn, "STAT %s", strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	// skip the first and last lines ("Status of ..." and "End of status")
+	if len(lines) < 3 {
+		return nil
+	}
+
+	for _, line := range lines[1 : len(lines)-1] {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			continue
 		}
 
-		if info == nil {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadDirNames fetches the names of the entries in a directory using "NLST".
+// This is much cheaper than ReadDir on servers that stat every file for
+// "MLSD"/"LIST", and some locked-down servers permit no other listing. Names
+// are returned as base names even if the server replies with full paths, and
+// entries for the current and parent directories are omitted. Note that many
+// servers exclude directories or hidden files from "NLST" output. Empty
+// directories return no names even if the server replies with an error such
+// as "450 No files found".
+func (c *Client) ReadDirNames(path string) ([]string, error) {
+	pconn, err := c.getIdleConn()
+	if err != nil {
+		return nil, err
+	}
+	defer c.returnConn(pconn)
+
+	lines, err := c.dataStringList(pconn, "NLST %s", path)
+	if noFilesFound(err) {
+		pconn.debug("no files in %s: %s", path, err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range lines {
+		name := nlstName(line)
+		if name == "" || name == "." || name == ".." {
 			continue
 		}
+		names = append(names, name)
+	}
 
-		ret = append(ret, info)
+	return names, nil
+}
+
+// Whether "err" is how the server answered "NLST" on an empty directory.
+// ProFTPD replies "450 No files found", and some servers send 550 instead.
+func noFilesFound(err error) bool {
+	fe, ok := err.(ftpError)
+	if !ok || (fe.code != replyTransientFileError && fe.code != replyFileError) {
+		return false
 	}
+	return strings.Contains(strings.ToLower(fe.msg), "no files found")
+}
 
-	return ret, nil
+// Reduce an "NLST" line to a base name. Servers variously reply with bare
+// names ("1234.bin") or paths ("subdir/1234.bin", "/pub/subdir/").
+func nlstName(line string) string {
+	line = strings.TrimRight(line, "/")
+	if line == "" {
+		return ""
+	}
+	return filepath.Base(line)
 }
 
 // Stat fetches details for a particular file. The os.FileInfo's fields may
@@ -196,35 +397,76 @@ func (c *Client) readDir(all bool, path string) ([]os.FileInfo, error) {
 // is a directory. You may have to set ServerLocation in your config to get
 // (more) accurate ModTimes when using "LIST".
 func (c *Client) Stat(path string) (os.FileInfo, error) {
+	if info, found := c.cache.stat(path); found {
+		return info, nil
+	}
+
 	pconn, err := c.getIdleConn()
 	if err != nil {
 		return nil, err
 	}
 	defer c.returnConn(pconn)
 
+	info, err := c.stat(pconn, path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.putStat(path, info)
+
+	return info, nil
+}
+
+func (c *Client) canMLST() bool {
+	pconn, err := c.getIdleConn()
+	if err != nil {
+		return false
+	}
+
+	defer c.returnConn(pconn)
+
+	return pconn.hasFeature("MLST")
+}
+
+func (c *Client) stat(pconn *persistentConn, path string) (os.FileInfo, error) {
 	if pconn.hasFeature("MLST") {
 		lines, err := c.controlStringList(pconn, "MLST %s", path)
 		if err == nil {
 			if len(lines) != 3 {
-				return nil, ftpError{err: fmt.Errorf("unexpected MLST response: %v", lines)}
+				return nil, pconn.withContext(ftpError{err: fmt.Errorf("unexpected MLST response: %v", lines), kind: KindProtocol})
 			}
-			return parseMLST(strings.TrimLeft(lines[1], " "), false)
+			info, err := parseMLST(strings.TrimLeft(lines[1], " "), false)
+			return info, pconn.addContext(err)
 		}
 		if !commandNotSupporterdError(err) {
 			return nil, err
 		}
 	}
 
-	lines, err := c.dataStringList(pconn, "LIST %s", path)
+	var lines []string
+	err := c.listFunc(pconn, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}, false, path)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(lines) != 1 {
-		return nil, ftpError{err: fmt.Errorf("unexpected LIST response: %v", lines)}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("unexpected LIST response: %v", lines), kind: KindProtocol})
+	}
+
+	listParsers := c.listParsers(pconn)
+
+	if c.needsLocation(pconn) {
+		dir := path[:strings.LastIndex(path, "/")+1]
+		if err := c.detectLocation(pconn, listParsers, dir, lines); err != nil {
+			return nil, err
+		}
 	}
 
-	return parseLIST(lines[0], c.config.ServerLocation, false)
+	info, err := parseLISTWith(listParsers, lines[0], c.serverLocation(pconn), false)
+	return info, pconn.addContext(err)
 }
 
 func extractDirName(msg string) (string, error) {
@@ -232,7 +474,8 @@ func extractDirName(msg string) (string, error) {
 	closeQuote := strings.LastIndex(msg, "\"")
 	if openQuote == -1 || len(msg) == openQuote+1 || closeQuote <= openQuote {
 		return "", ftpError{
-			err: fmt.Errorf("failed parsing directory name: %s", msg),
+			err:  fmt.Errorf("failed parsing directory name: %s", msg),
+			kind: KindProtocol,
 		}
 	}
 	return strings.Replace(msg[openQuote+1:closeQuote], `""`, `"`, -1), nil
@@ -248,28 +491,52 @@ func (c *Client) controlStringList(pconn *persistentConn, f string, args ...inte
 
 	if !positiveCompletionReply(code) {
 		pconn.debug("unexpected response to %s: %d-%s", cmd, code, msg)
-		return nil, ftpError{code: code, msg: msg}
+		return nil, pconn.replyError(code, msg)
 	}
 
 	return strings.Split(msg, "\n"), nil
 }
 
 func (c *Client) dataStringList(pconn *persistentConn, f string, args ...interface{}) ([]string, error) {
-	dcGetter, err := pconn.prepareDataConn()
+	var res []string
+	err := c.dataStringFunc(pconn, func(line string) error {
+		res = append(res, line)
+		return nil
+	}, f, args...)
 	if err != nil {
 		return nil, err
 	}
 
+	return res, nil
+}
+
+// Run data command fmt.Sprintf(f, args...), calling "fn" for each line of
+// the response as it is received. If "fn" returns an error, the transfer is
+// abandoned and the error is returned.
+func (c *Client) dataStringFunc(pconn *persistentConn, fn func(string) error, f string, args ...interface{}) error {
+	dcGetter, err := pconn.prepareDataConn()
+	if err != nil {
+		if fe, ok := err.(ftpError); ok {
+			fe.dataConn = true
+			err = fe
+		}
+		return err
+	}
+
 	cmd := fmt.Sprintf(f, args...)
 
 	err = pconn.sendCommandExpected(replyGroupPreliminaryReply, cmd)
 	if err != nil {
-		return nil, err
+		if fe, ok := err.(ftpError); ok && fe.code == replyCantOpenDataConnection {
+			fe.dataConn = true
+			err = fe
+		}
+		return err
 	}
 
 	dc, err := dcGetter()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// to catch early returns
@@ -278,18 +545,31 @@ func (c *Client) dataStringList(pconn *persistentConn, f string, args ...interfa
 	scanner := bufio.NewScanner(dc)
 	scanner.Split(bufio.ScanLines)
 
-	var res []string
 	for scanner.Scan() {
-		res = append(res, scanner.Text())
+		if fnErr := fn(scanner.Text()); fnErr != nil {
+			pconn.debug("abandoning %s: %s", cmd, fnErr)
+
+			// Closing our end makes the server give up on the transfer. Its
+			// reply depends on how much it had sent already (typically 426 or
+			// 226), so don't bother checking the code.
+			dc.Close()
+			if _, _, err := pconn.readResponse(); err != nil {
+				return err
+			}
+
+			return fnErr
+		}
 	}
 
 	var dataError error
 	if err = scanner.Err(); err != nil {
 		pconn.debug("error reading %s data: %s", cmd, err)
-		dataError = ftpError{
+		dataError = pconn.withContext(ftpError{
 			err:       fmt.Errorf("error reading %s data: %s", cmd, err),
 			temporary: true,
-		}
+			timeout:   isTimeout(err),
+			kind:      KindDataConn,
+		})
 	}
 
 	err = dc.Close()
@@ -299,19 +579,15 @@ func (c *Client) dataStringList(pconn *persistentConn, f string, args ...interfa
 
 	code, msg, err := pconn.readResponse()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if !positiveCompletionReply(code) {
 		pconn.debug("unexpected result: %d-%s", code, msg)
-		return nil, ftpError{code: code, msg: msg}
-	}
-
-	if dataError != nil {
-		return nil, dataError
+		return pconn.replyError(code, msg)
 	}
 
-	return res, nil
+	return dataError
 }
 
 type ftpFile struct {
@@ -319,7 +595,10 @@ type ftpFile struct {
 	size  int64
 	mode  os.FileMode
 	mtime time.Time
-	raw   string
+	facts *FileFacts
+
+	// symlink target as reported by the server, if any
+	linkTarget string
 }
 
 func (f *ftpFile) Name() string {
@@ -342,26 +621,107 @@ func (f *ftpFile) IsDir() bool {
 	return f.mode.IsDir()
 }
 
+// Sys returns a *FileFacts.
 func (f *ftpFile) Sys() interface{} {
-	return f.raw
+	return f.facts
+}
+
+// ListParser parses entries of "LIST" output the built-in parsers don't
+// understand. See Config.ListParsers.
+//
+// ParseLIST is called with a single line of output and the server's time zone
+// (see Config.ServerLocation). If the entry describes a file, it returns the
+// file's info (skip is then ignored). If the entry is in the parser's format
+// but doesn't describe a file (e.g. a header or footer), it returns
+// skip == true to ignore the line. If the entry isn't in the parser's format
+// at all, it returns a nil info and skip == false so the next parser is
+// tried. A non-nil error aborts the listing.
+type ListParser interface {
+	ParseLIST(entry string, loc *time.Location) (info os.FileInfo, skip bool, err error)
 }
 
-var lsRegex = regexp.MustCompile(`^\s*(\S)(\S{3})(\S{3})(\S{3})(?:\s+\S+){3}\s+(\d+)\s+(\w+\s+\d+)\s+([\d:]+)\s+(.+)$`)
+// ListParserFunc adapts an ordinary function to the ListParser interface.
+type ListParserFunc func(entry string, loc *time.Location) (info os.FileInfo, skip bool, err error)
+
+// ParseLIST calls f(entry, loc).
+func (f ListParserFunc) ParseLIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	return f(entry, loc)
+}
+
+// The built-in formats, tried in order by parseLIST. A nil os.FileInfo with
+// skip == true means the entry is in that format but isn't a file.
+var listFormats = []ListParser{
+	ListParserFunc(parseUnixLIST),
+	ListParserFunc(parseDOSLIST),
+	ListParserFunc(parseEPLF),
+	ListParserFunc(parseNetWareLIST),
+	ListParserFunc(parseOS400LIST),
+	ListParserFunc(parseVMSLIST),
+	ListParserFunc(parseMVSLIST),
+}
+
+// The parsers to try for "LIST" entries: the configured ones around the
+// built-in formats, starting with the one matching the server's "SYST"
+// reply, if any.
+func (c *Client) listParsers(pconn *persistentConn) []ListParser {
+	preferred := pconn.systemListFormat()
+	if preferred == nil && len(c.config.ListParsers) == 0 && len(c.config.FallbackListParsers) == 0 {
+		return listFormats
+	}
+
+	parsers := make([]ListParser, 0, len(c.config.ListParsers)+len(listFormats)+len(c.config.FallbackListParsers)+1)
+	parsers = append(parsers, c.config.ListParsers...)
+	if preferred != nil {
+		parsers = append(parsers, preferred)
+	}
+	parsers = append(parsers, listFormats...)
+	return append(parsers, c.config.FallbackListParsers...)
+}
 
-// total 404456
-// drwxr-xr-x   8 goftp    20            272 Jul 28 05:03 git-ignored
 func parseLIST(entry string, loc *time.Location, skipSelfParent bool) (os.FileInfo, error) {
-	if strings.HasPrefix(entry, "total ") {
+	return parseLISTWith(listFormats, entry, loc, skipSelfParent)
+}
+
+func parseLISTWith(parsers []ListParser, entry string, loc *time.Location, skipSelfParent bool) (os.FileInfo, error) {
+	if strings.HasPrefix(entry, "total ") || strings.TrimSpace(entry) == "" {
 		return nil, nil
 	}
 
-	matches := lsRegex.FindStringSubmatch(entry)
-	if len(matches) == 0 {
-		return nil, ftpError{err: fmt.Errorf(`failed parsing LIST entry: %s`, entry)}
+	for _, parser := range parsers {
+		info, skip, err := parser.ParseLIST(entry, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		if info == nil {
+			if skip {
+				return nil, nil
+			}
+			continue
+		}
+
+		if skipSelfParent && (info.Name() == "." || info.Name() == "..") {
+			return nil, nil
+		}
+
+		return info, nil
 	}
 
-	if skipSelfParent && (matches[8] == "." || matches[8] == "..") {
-		return nil, nil
+	return nil, ftpError{err: fmt.Errorf(`failed parsing LIST entry: %s`, entry), kind: KindProtocol}
+}
+
+// type, permissions, link count, owner, optional group, size (or device
+// numbers), month and day, time or year, name
+var lsRegex = regexp.MustCompile(`^\s*(\S)(\S{3})(\S{3})(\S{3})\S?\s+(\d+)\s+(\S+)\s+(?:(\S+)\s+)?(\d+|\d+,\s*\d+)\s+(\w{3}\s+\d{1,2})\s+(\d{1,2}:\d{2}|\d{4})\s(.+)$`)
+
+// total 404456
+// drwxr-xr-x   8 goftp    20            272 Jul 28 05:03 git-ignored
+// crw-rw-rw-   1 root     root         1, 3 Jul 28  2015 null
+// -rw-r--r--   1 1000               4 Jul 28  2015 no group
+func parseUnixLIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	matches := lsRegex.FindStringSubmatch(entry)
+	if len(matches) == 0 {
+		return nil, false, nil
 	}
 
 	var mode os.FileMode
@@ -370,53 +730,121 @@ func parseLIST(entry string, loc *time.Location, skipSelfParent bool) (os.FileIn
 		mode |= os.ModeDir
 	case "l":
 		mode |= os.ModeSymlink
+	case "b":
+		mode |= os.ModeDevice
+	case "c":
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case "p":
+		mode |= os.ModeNamedPipe
+	case "s":
+		mode |= os.ModeSocket
 	}
 
 	for i := 0; i < 3; i++ {
-		if matches[i+2][0] == 'r' {
+		perm := matches[i+2]
+		if perm[0] == 'r' {
 			mode |= os.FileMode(04 << (3 * uint(2-i)))
 		}
-		if matches[i+2][1] == 'w' {
+		if perm[1] == 'w' {
 			mode |= os.FileMode(02 << (3 * uint(2-i)))
 		}
-		if matches[i+2][2] == 'x' || matches[i+2][2] == 's' {
+		switch perm[2] {
+		case 'x':
 			mode |= os.FileMode(01 << (3 * uint(2-i)))
+		case 's', 't':
+			mode |= os.FileMode(01<<(3*uint(2-i))) | specialModes[i]
+		case 'S', 'T':
+			mode |= specialModes[i]
 		}
 	}
 
-	size, err := strconv.ParseUint(matches[5], 10, 64)
+	nlink, err := strconv.Atoi(matches[5])
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf(`failed parsing LIST entry's size: %s (%s)`, err, entry)}
+		return nil, true, ftpError{err: fmt.Errorf(`failed parsing LIST entry's link count: %s (%s)`, err, entry), kind: KindProtocol}
 	}
 
-	var mtime time.Time
-	if strings.Contains(matches[7], ":") {
-		mtime, err = time.ParseInLocation("Jan _2 15:04", matches[6]+" "+matches[7], loc)
-		if err == nil {
-			now := time.Now()
-			year := now.Year()
-			if mtime.Month() > now.Month() {
-				year--
-			}
-			mtime, err = time.ParseInLocation("Jan _2 15:04 2006", matches[6]+" "+matches[7]+" "+strconv.Itoa(year), loc)
+	facts := &FileFacts{
+		Raw:   entry,
+		Owner: matches[6],
+		Group: matches[7],
+		Nlink: nlink,
+	}
+
+	// device files list "major, minor" instead of a size
+	var size uint64
+	if comma := strings.IndexByte(matches[8], ','); comma != -1 {
+		facts.Extra = map[string]string{
+			"device": matches[8][:comma] + "," + strings.TrimSpace(matches[8][comma+1:]),
 		}
 	} else {
-		mtime, err = time.ParseInLocation("Jan _2 2006", matches[6]+" "+matches[7], loc)
+		size, err = strconv.ParseUint(matches[8], 10, 64)
+		if err != nil {
+			return nil, true, ftpError{err: fmt.Errorf(`failed parsing LIST entry's size: %s (%s)`, err, entry), kind: KindProtocol}
+		}
 	}
 
+	mtime, err := parseLSTime(matches[9], matches[10], loc)
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf(`failed parsing LIST entry's mtime: %s (%s)`, err, entry)}
+		return nil, true, ftpError{err: fmt.Errorf(`failed parsing LIST entry's mtime: %s (%s)`, err, entry), kind: KindProtocol}
+	}
+
+	name := matches[11]
+	var linkTarget string
+	if mode&os.ModeSymlink != 0 {
+		// lrwxrwxrwx   1 goftp    goftp          6 Sep 28  2015 slinkdir -> subdir
+		if idx := strings.Index(name, " -> "); idx != -1 {
+			linkTarget = name[idx+4:]
+			name = name[:idx]
+		}
 	}
 
 	info := &ftpFile{
-		name:  filepath.Base(matches[8]),
-		mode:  mode,
-		mtime: mtime,
-		raw:   entry,
-		size:  int64(size),
+		name:       filepath.Base(name),
+		mode:       mode,
+		mtime:      mtime,
+		facts:      facts,
+		size:       int64(size),
+		linkTarget: linkTarget,
 	}
 
-	return info, nil
+	return info, true, nil
+}
+
+// The mode bits set by "s"/"S" and "t"/"T" in the user, group and other
+// permissions.
+var specialModes = [3]os.FileMode{os.ModeSetuid, os.ModeSetgid, os.ModeSticky}
+
+// How far in the future a "LIST" timestamp without a year may be.
+const maxClockSkew = 24 * time.Hour
+
+// Parse an "ls -l" style timestamp, i.e. "Jul 28" followed by either a time
+// of day (for recent files) or a year.
+func parseLSTime(monthDay, timeOrYear string, loc *time.Location) (time.Time, error) {
+	if !strings.Contains(timeOrYear, ":") {
+		return time.ParseInLocation("Jan _2 2006", monthDay+" "+timeOrYear, loc)
+	}
+
+	// parse in a leap year so "Feb 29" is accepted
+	t, err := time.ParseInLocation("Jan _2 15:04 2006", monthDay+" "+timeOrYear+" 2000", loc)
+	if err != nil {
+		return t, err
+	}
+
+	inYear := func(year int) (time.Time, bool) {
+		mtime := time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+		return mtime, mtime.Day() == t.Day()
+	}
+
+	// ls only shows the time of day for recent files (some servers do for
+	// everything from the current year), so use the most recent year that
+	// doesn't put the date in the future. Allow a day for clock skew between
+	// us and the server. Looping also finds the last year with a Feb 29.
+	limit := time.Now().In(loc).Add(maxClockSkew)
+	for year := limit.Year(); ; year-- {
+		if mtime, ok := inYear(year); ok && !mtime.After(limit) {
+			return mtime, nil
+		}
+	}
 }
 
 type mlstParser struct{}
@@ -433,19 +861,22 @@ const (
 	mlstFilename
 )
 
+// facts that end up in os.FileInfo rather than FileFacts
 type mlstFacts struct {
-	typ      string
-	unixMode string
-	perm     string
-	size     string
-	sizd     string
-	modify   string
+	typ        string
+	linkTarget string
+	unixMode   string
+	perm       string
+	size       string
+	sizd       string
+	modify     string
 }
 
 // an entry looks something like this:
 // type=file;size=12;modify=20150216084148;UNIX.mode=0644;unique=1000004g1187ec7; lorem.txt
 func (p mlstParser) parse(entry string, skipSelfParent bool) (os.FileInfo, error) {
 	var facts mlstFacts
+	sys := &FileFacts{Raw: entry}
 	state := mlstFactName
 	var left string // Previous token.
 	var i1 int      // Current token's start position.
@@ -458,21 +889,55 @@ func (p mlstParser) parse(entry string, skipSelfParent bool) (os.FileInfo, error
 				}
 				var (
 					key = strings.ToLower(left[:len(left)-1])
-					val = strings.ToLower(entry[i1:i2])
+					raw = entry[i1:i2]
+					val = strings.ToLower(raw)
 				)
 				switch key {
 				case "type":
 					facts.typ = val
+					sys.Type = raw
+					// type=OS.unix=slink:/some/target (target keeps its case)
+					if strings.HasPrefix(val, "os.unix=slink:") {
+						facts.linkTarget = raw[len("os.unix=slink:"):]
+					}
 				case "unix.mode":
 					facts.unixMode = val
 				case "perm":
 					facts.perm = val
+					sys.Perm = raw
 				case "size":
 					facts.size = val
 				case "sizd":
 					facts.sizd = val
 				case "modify":
 					facts.modify = val
+				case "unique":
+					sys.Unique = raw
+				case "create":
+					sys.Create, _ = p.parseModTime(val)
+				case "unix.owner":
+					sys.Owner = raw
+				case "unix.group":
+					sys.Group = raw
+				case "unix.ownername":
+					sys.OwnerName = raw
+				case "unix.groupname":
+					sys.GroupName = raw
+				case "unix.uid":
+					sys.UID = raw
+				case "unix.gid":
+					sys.GID = raw
+				case "media-type":
+					sys.MediaType = raw
+				case "charset":
+					sys.Charset = raw
+				case "lang":
+					sys.Lang = raw
+				default:
+					if sys.Extra == nil {
+						sys.Extra = make(map[string]string)
+					}
+					sys.Extra[key] = raw
 				}
 				if len(entry) >= i2+1 && entry[i2+1] == ' ' {
 					state = mlstFilename
@@ -566,25 +1031,44 @@ func (p mlstParser) parse(entry string, skipSelfParent bool) (os.FileInfo, error
 	}
 
 	info := &ftpFile{
-		name:  filepath.Base(filename),
-		size:  size,
-		mtime: mtime,
-		raw:   entry,
-		mode:  mode,
+		name:       filepath.Base(filename),
+		size:       size,
+		mtime:      mtime,
+		facts:      sys,
+		mode:       mode,
+		linkTarget: facts.linkTarget,
 	}
 
 	return info, nil
 }
 
 func (p mlstParser) error(entry string) error {
-	return ftpError{err: fmt.Errorf(`failed parsing MLST entry: %s`, entry)}
+	return ftpError{err: fmt.Errorf(`failed parsing MLST entry: %s`, entry), kind: KindProtocol}
 }
 
 func (p mlstParser) incompleteError(entry string) error {
-	return ftpError{err: fmt.Errorf(`MLST entry incomplete: %s`, entry)}
+	return ftpError{err: fmt.Errorf(`MLST entry incomplete: %s`, entry), kind: KindProtocol}
 }
 
+// Parse an RFC 3659 time-val: YYYYMMDDHHMMSS[.sss]
 func (p *mlstParser) parseModTime(value string) (time.Time, bool) {
+	var nsec int
+	if len(value) > 15 && value[14] == '.' {
+		frac := value[15:]
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		n, err := strconv.ParseUint(frac, 10, 32)
+		if err != nil {
+			return time.Time{}, false
+		}
+		nsec = int(n)
+		for i := len(frac); i < 9; i++ {
+			nsec *= 10
+		}
+		value = value[:14]
+	}
+
 	if len(value) != 14 {
 		return time.Time{}, false
 	}
@@ -613,5 +1097,5 @@ func (p *mlstParser) parseModTime(value string) (time.Time, bool) {
 		return time.Time{}, false
 	}
 	return time.Date(int(year), time.Month(month), int(day),
-		int(hour), int(min), int(sec), 0, time.UTC), true
+		int(hour), int(min), int(sec), nsec, time.UTC), true
 }
@@ -6,8 +6,11 @@ package goftp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"net"
 	"os"
 	"path"
 	"reflect"
@@ -189,23 +192,190 @@ func TestParseMLST(t *testing.T) {
 				size:  6,
 			},
 		},
+		{
+			// slink target keeps its case
+			"type=OS.unix=slink:/Home/Docs;size=10;modify=20140728100902;UNIX.mode=0777; docs",
+			&ftpFile{
+				name:       "docs",
+				mtime:      mustParseTime(timeFormat, "20140728100902"),
+				mode:       os.FileMode(0777) | os.ModeSymlink,
+				size:       10,
+				linkTarget: "/Home/Docs",
+			},
+		},
 	}
 
 	var parser mlstParser
 	for _, c := range cases {
-		c.exp.raw = c.raw
-
 		got, err := parser.parse(c.raw, false)
 		if err != nil {
 			t.Fatal(err)
 		}
 		gotFile := got.(*ftpFile)
+		if gotFile.facts == nil || gotFile.facts.Raw != c.raw {
+			t.Errorf("expected raw entry in facts, got %+v", gotFile.facts)
+		}
+
+		// facts are covered by TestParseMLSTFacts
+		c.exp.facts = gotFile.facts
+
 		if !reflect.DeepEqual(gotFile, c.exp) {
 			t.Errorf("exp %+v\n got %+v", c.exp, gotFile)
 		}
 	}
 }
 
+func TestParseMLSTFacts(t *testing.T) {
+	raw := "modify=20150928140340;create=20150101120000.5;perm=adfrw;size=6;type=File;unique=801U5AA227;" +
+		"UNIX.group=1000;UNIX.groupname=goftp;UNIX.mode=0644;UNIX.owner=1000;UNIX.ownername=goftp;" +
+		"UNIX.uid=1000;UNIX.gid=1000;media-type=text/plain;charset=UTF-8;lang=en;x.custom=Foo; readme.txt"
+
+	info, err := parseMLST(raw, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts, ok := info.Sys().(*FileFacts)
+	if !ok {
+		t.Fatalf("got %T", info.Sys())
+	}
+
+	exp := &FileFacts{
+		Raw:       raw,
+		Type:      "File",
+		Unique:    "801U5AA227",
+		Perm:      "adfrw",
+		Create:    time.Date(2015, 1, 1, 12, 0, 0, 500000000, time.UTC),
+		Owner:     "1000",
+		Group:     "1000",
+		OwnerName: "goftp",
+		GroupName: "goftp",
+		UID:       "1000",
+		GID:       "1000",
+		MediaType: "text/plain",
+		Charset:   "UTF-8",
+		Lang:      "en",
+		Extra:     map[string]string{"x.custom": "Foo"},
+	}
+
+	if !reflect.DeepEqual(facts, exp) {
+		t.Errorf("exp %+v\n got %+v", exp, facts)
+	}
+
+	if !facts.HasPerm('r') || facts.HasPerm('m') {
+		t.Error("wrong perms")
+	}
+
+	if !(&FileFacts{}).HasPerm('w') {
+		t.Error("missing perm fact shouldn't deny anything")
+	}
+}
+
+func TestParseLISTSymlink(t *testing.T) {
+	cases := []struct {
+		raw    string
+		name   string
+		target string
+	}{
+		{"lrwxrwxrwx   1 goftp    goftp          6 Sep 28  2015 slinkdir -> subdir", "slinkdir", "subdir"},
+		{"lrwxrwxrwx   1 goftp    goftp         17 Sep 28  2015 etc -> /usr/local/etc/ftp", "etc", "/usr/local/etc/ftp"},
+		{"lrwxrwxrwx   1 goftp    goftp          9 Sep 28  2015 has -> arrow -> target", "has", "arrow -> target"},
+		{"-rw-r--r--   1 goftp    goftp          6 Sep 28  2015 not -> a link", "not -> a link", ""},
+	}
+
+	for _, c := range cases {
+		info, err := parseLIST(c.raw, time.UTC, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := info.(*ftpFile)
+		if f.name != c.name || f.linkTarget != c.target {
+			t.Errorf("%s: got name %q, target %q", c.raw, f.name, f.linkTarget)
+		}
+	}
+}
+
+func TestParseLISTUnix(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"-rw-r--r--   1 goftp    goftp           4 Jul 28  2015 1234.bin",
+			"1234.bin", 4, 0644,
+			time.Date(2015, 7, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"-rw-r--r--   1 goftp    goftp           4 Jul 28  2015  leading space",
+			" leading space", 4, 0644,
+			time.Date(2015, 7, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"-rw-r--r--   1 1000           4 Jul 28  2015 no group",
+			"no group", 4, 0644,
+			time.Date(2015, 7, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"crw-rw-rw-   1 root     root       1,   3 Jul 28  2015 null",
+			"null", 0, os.ModeDevice | os.ModeCharDevice | 0666,
+			time.Date(2015, 7, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"drwxrwxrwt+  9 root     root        4096 Jul 28  2015 tmp",
+			"tmp", 4096, os.ModeDir | os.ModeSticky | 0777,
+			time.Date(2015, 7, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"-rwsr-Sr-x   1 root     root       54256 Jul 28  2015 passwd",
+			"passwd", 54256, os.ModeSetuid | os.ModeSetgid | 0745,
+			time.Date(2015, 7, 28, 0, 0, 0, 0, time.UTC),
+		},
+	})
+
+	info, err := parseLIST("crw-rw-rw-   3 root     wheel      1,   3 Jul 28  2015 null", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := info.Sys().(*FileFacts)
+	if facts.Owner != "root" || facts.Group != "wheel" || facts.Nlink != 3 || facts.Extra["device"] != "1,3" {
+		t.Errorf("got facts %+v", facts)
+	}
+}
+
+func TestParseLISTYear(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	// recent, older and slightly future-dated files, on either side of a year
+	// boundary
+	for _, mtime := range []time.Time{
+		now,
+		now.Add(time.Hour),
+		now.AddDate(0, -1, 0),
+		now.AddDate(0, -5, 0),
+		now.AddDate(0, -8, 0),
+		now.AddDate(0, -11, 0),
+	} {
+		raw := "-rw-r--r--   1 goftp    goftp           4 " + mtime.Format("Jan _2 15:04") + " file"
+
+		info, err := parseLIST(raw, time.UTC, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("%s: exp %s, got %s", raw, mtime, info.ModTime())
+		}
+	}
+
+	info, err := parseLIST("-rw-r--r--   1 goftp    goftp           4 Feb 29 12:00 leap", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mtime := info.ModTime(); mtime.Month() != time.February || mtime.Day() != 29 || mtime.After(now.AddDate(1, 0, 0)) {
+		t.Errorf("got %s", mtime)
+	}
+}
+
 var mlstCases = []string{
 	"modify=20160513014228;perm=adfrw;size=399;type=file;unique=FD00U29043978;UNIX.group=1170;UNIX.mode=0644;UNIX.owner=1168; 408.php",
 	"modify=20180407164538;perm=adfrw;size=381514;type=file;unique=FD00U4565E18;UNIX.group=1170;UNIX.mode=0644;UNIX.owner=1168; browscap.ini",
@@ -309,7 +479,7 @@ func TestReadDir(t *testing.T) {
 			}
 
 			if err := compareFileInfos(item, expected); err != nil {
-				t.Errorf("mismatch on %s: %s (%s)", item.Name(), err, item.Sys().(string))
+				t.Errorf("mismatch on %s: %s (%s)", item.Name(), err, item.Sys().(*FileFacts).Raw)
 			}
 
 			names = append(names, item.Name())
@@ -360,7 +530,7 @@ func TestReadDirNoMLSD(t *testing.T) {
 			}
 
 			if err := compareFileInfos(item, expected); err != nil {
-				t.Errorf("mismatch on %s: %s (%s)", item.Name(), err, item.Sys().(string))
+				t.Errorf("mismatch on %s: %s (%s)", item.Name(), err, item.Sys().(*FileFacts).Raw)
 			}
 
 			names = append(names, item.Name())
@@ -378,6 +548,256 @@ func TestReadDirNoMLSD(t *testing.T) {
 	}
 }
 
+func TestReadDirFunc(t *testing.T) {
+	for _, addr := range ftpdAddrs {
+		c, err := DialConfig(goftpConfig, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		err = c.ReadDirFunc("", func(info os.FileInfo) error {
+			names = append(names, info.Name())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, []string{"git-ignored", "lorem.txt", "subdir"}) {
+			t.Errorf("got: %v", names)
+		}
+
+		// stop after the first entry
+		var count int
+		err = c.ReadDirFunc("", func(info os.FileInfo) error {
+			count++
+			return fs.SkipAll
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count != 1 {
+			t.Errorf("expected 1 entry, got %d", count)
+		}
+
+		stopErr := errors.New("stop")
+		err = c.ReadDirFunc("", func(info os.FileInfo) error {
+			return stopErr
+		})
+		if err != stopErr {
+			t.Errorf("expected stop error, got %v", err)
+		}
+
+		// connection should still be in sync
+		list, err := c.ReadDir("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(list) != 3 {
+			t.Errorf("expected 3 items, got %d", len(list))
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
+
+func TestReadDirNames(t *testing.T) {
+	for _, addr := range ftpdAddrs {
+		c, err := DialConfig(goftpConfig, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names, err := c.ReadDirNames("subdir")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(names, []string{"1234.bin"}) {
+			t.Errorf("got: %v", names)
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
+
+func TestParseNLSTName(t *testing.T) {
+	cases := map[string]string{
+		"1234.bin":            "1234.bin",
+		"subdir/1234.bin":     "1234.bin",
+		"/pub/subdir/":        "subdir",
+		"./lorem.txt":         "lorem.txt",
+		"name with spaces.go": "name with spaces.go",
+		"/":                   "",
+		"":                    "",
+	}
+
+	for line, exp := range cases {
+		if got := nlstName(line); got != exp {
+			t.Errorf("%q: expected %q, got %q", line, exp, got)
+		}
+	}
+}
+
+func TestReadDirNamesEmpty(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	port := l.Addr().(*net.TCPAddr).Port
+
+	c := newClient(Config{
+		stubResponses: map[string]stubResponse{
+			"PASV":      {227, fmt.Sprintf("Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)},
+			"NLST 450":  {450, "No files found"},
+			"NLST 550":  {550, "No files found."},
+			"NLST gone": {550, "gone: No such file or directory"},
+		},
+	}, []hostAddr{{addr: "127.0.0.1:21"}})
+
+	pconn := &persistentConn{config: c.config, features: map[string]string{}, epsvNotSupported: true}
+	c.pinned = pconn
+	c.freeConnCh <- pconn
+
+	for _, dir := range []string{"450", "550"} {
+		names, err := c.ReadDirNames(dir)
+		if err != nil || len(names) != 0 {
+			t.Errorf("%s: got %v, %v", dir, names, err)
+		}
+	}
+
+	if _, err := c.ReadDirNames("gone"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestStatListing(t *testing.T) {
+	config := Config{
+		StatListMode: StatListAlways,
+		stubResponses: map[string]stubResponse{
+			"STAT -l subdir": {213, "Status of subdir:\n" +
+				" -rw-r--r--   1 goftp    goftp           4 Jul 28  2015 1234.bin\n" +
+				" drwxr-xr-x   2 goftp    goftp        4096 Jul 28  2015 nested\n" +
+				"End of status"},
+			"STAT -l subdir/1234.bin": {213, "Status of subdir/1234.bin:\n" +
+				"-rw-r--r--   1 goftp    goftp           4 Jul 28  2015 subdir/1234.bin\n" +
+				"End of status"},
+			"STAT -l empty": {213, "Status of empty:\nEnd of status"},
+		},
+	}
+
+	c := newClient(config, []hostAddr{{addr: "127.0.0.1:21"}})
+	pconn := &persistentConn{
+		config:   c.config,
+		features: map[string]string{"MLST": ""},
+	}
+
+	var names []string
+	err := c.listDir(pconn, false, "subdir", func(info os.FileInfo) error {
+		names = append(names, info.Name())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"1234.bin", "nested"}) {
+		t.Errorf("got: %v", names)
+	}
+
+	err = c.listDir(pconn, false, "empty", func(info os.FileInfo) error {
+		t.Errorf("unexpected entry %s", info.Name())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pconn.features = map[string]string{}
+	info, err := c.stat(pconn, "subdir/1234.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Name() != "1234.bin" || info.Size() != 4 {
+		t.Errorf("got %s (%d bytes)", info.Name(), info.Size())
+	}
+}
+
+func TestStatListingSystem(t *testing.T) {
+	config := Config{
+		StatListMode: StatListAlways,
+		stubResponses: map[string]stubResponse{
+			// no "-l" for non-Unix servers
+			"STAT PDS.NAME": {213, "Status of PDS.NAME:\n" +
+				" Name     VV.MM   Created       Changed      Size  Init   Mod   Id\n" +
+				"MEMBER1   01.01 2002/09/12 2002/09/13 10:30    10    10     0 USER1\n" +
+				"End of status"},
+			"STAT .": {213, "Status of .:\n" +
+				"Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname\n" +
+				"WYOSPT 3390   **NONE**    1    1  FB      80  6160  PO  PDS.NAME\n" +
+				"End of status"},
+		},
+	}
+
+	c := newClient(config, []hostAddr{{addr: "127.0.0.1:21"}})
+	pconn := &persistentConn{
+		config:   c.config,
+		features: map[string]string{},
+		system:   "MVS is the operating system of this server. FTP Server is running on z/OS.",
+	}
+
+	for path, exp := range map[string][]string{"PDS.NAME": {"MEMBER1"}, "": {"PDS.NAME"}} {
+		var names []string
+		err := c.listDir(pconn, true, path, func(info os.FileInfo) error {
+			names = append(names, info.Name())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(names, exp) {
+			t.Errorf("%q: got %v", path, names)
+		}
+	}
+}
+
+func TestReadDirStatListing(t *testing.T) {
+	for _, addr := range proAddrs {
+		config := goftpConfig
+		config.StatListMode = StatListAlways
+
+		c, err := DialConfig(config, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := c.ReadDir("subdir")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(list) != 1 || list[0].Name() != "1234.bin" || list[0].Size() != 4 {
+			t.Errorf("Unexpected listing: %v", list)
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
+
 func TestStat(t *testing.T) {
 	for _, addr := range ftpdAddrs {
 		c, err := DialConfig(goftpConfig, addr)
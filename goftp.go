@@ -37,14 +37,22 @@ func DialConfig(config Config, hosts ...string) (*Client, error) {
 
 var hasPort = regexp.MustCompile(`^[^:]+:\d+$|\]:\d+$`)
 
-func lookupHosts(hosts []string, ipv6Lookup bool) ([]string, error) {
+// A server address and the hostname (or IP) it was resolved from, which TLS
+// connections verify certificates against.
+type hostAddr struct {
+	addr       string
+	serverName string
+}
+
+// Expand "hosts" to IP addresses with ports.
+func lookupHosts(hosts []string, ipv6Lookup bool) ([]hostAddr, error) {
 	if len(hosts) == 0 {
 		return nil, errors.New("must specify at least one host")
 	}
 
 	var (
-		ret  []string
-		ipv6 []string
+		ret  []hostAddr
+		ipv6 []hostAddr
 	)
 
 	for i, host := range hosts {
@@ -58,7 +66,7 @@ func lookupHosts(hosts []string, ipv6Lookup bool) ([]string, error) {
 
 		if net.ParseIP(hostnameOrIP) != nil {
 			// is IP, add to list
-			ret = append(ret, host)
+			ret = append(ret, hostAddr{addr: host, serverName: hostnameOrIP})
 		} else {
 			// not an IP, must be hostname
 			ips, err := net.LookupIP(hostnameOrIP)
@@ -69,7 +77,10 @@ func lookupHosts(hosts []string, ipv6Lookup bool) ([]string, error) {
 			}
 
 			for _, ip := range ips {
-				ipAndPort := fmt.Sprintf("[%s]:%s", ip.String(), port)
+				ipAndPort := hostAddr{
+					addr:       fmt.Sprintf("[%s]:%s", ip.String(), port),
+					serverName: hostnameOrIP,
+				}
 				if ip.To4() == nil && !ipv6Lookup {
 					ipv6 = append(ipv6, ipAndPort)
 				} else {
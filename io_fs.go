@@ -0,0 +1,257 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// FS is an fs.FS backed by a Client, rooted at a directory on the server. It
+// also implements fs.ReadDirFS, fs.StatFS and fs.ReadFileFS, so FTP trees can
+// be used with fs.WalkDir, template.ParseFS, http.FS and friends. FS is safe
+// to use concurrently since each call checks out its own pooled connection.
+type FS struct {
+	client *Client
+	root   string
+}
+
+// FS returns an fs.FS serving the tree rooted at "root" on the server. An
+// empty root refers to the connection's initial working directory.
+func (c *Client) FS(root string) *FS {
+	return &FS{client: c, root: root}
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// Translate a slash-separated fs.FS name into a server path.
+func (fsys *FS) serverPath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return fsys.root, nil
+	}
+	return path.Join(fsys.root, name), nil
+}
+
+// Open opens the named file or directory. Files are streamed from the server
+// as they are read, so callers must Close them to release the connection.
+// Unlike ReadFile, reading doesn't resume failed transfers.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		err.(*fs.PathError).Op = "open"
+		return nil, err
+	}
+
+	p, _ := fsys.serverPath("open", name)
+
+	if info.IsDir() {
+		return &fsDir{fsys: fsys, name: name, info: info}, nil
+	}
+
+	// unlike Retrieve, don't resume after Close aborts the transfer
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := fsys.client.transferFromOffset(p, pw, nil, 0)
+		pw.CloseWithError(err)
+	}()
+
+	return &fsFile{name: name, info: info, reader: pr}, nil
+}
+
+// Stat returns an fs.FileInfo describing the named file.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	p, err := fsys.serverPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fsys.stat(p, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+
+	return fsFileInfo{FileInfo: info, name: path.Base(name)}, nil
+}
+
+// Without "MLST", Client.Stat falls back to "LIST", which lists the contents
+// of directories rather than describing them. In that case look the name up
+// in its parent's listing instead, and assume the root is a directory.
+func (fsys *FS) stat(p, name string) (fs.FileInfo, error) {
+	if fsys.client.canMLST() {
+		return fsys.client.Stat(p)
+	}
+
+	if name == "." {
+		return &ftpFile{name: ".", mode: fs.ModeDir}, nil
+	}
+
+	parent, _ := fsys.serverPath("stat", path.Dir(name))
+
+	infos, err := fsys.client.ReadDir(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.Name() == path.Base(name) {
+			return info, nil
+		}
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+// ReadDir reads the named directory and returns its entries sorted by
+// filename. Symlinks are reported as fs.ModeSymlink even if FollowSymlinks
+// found that they point to directories, so fs.WalkDir doesn't descend into
+// them (and loop on links to ancestors).
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := fsys.serverPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := fsys.client.ReadDir(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		if info.Mode()&fs.ModeSymlink != 0 {
+			info = symlinkInfo{info}
+		}
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// ReadFile retrieves the named file and returns its contents.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	p, err := fsys.serverPath("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := fsys.client.Retrieve(p, buf); err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// fsFileInfo reports the fs.FS base name rather than whatever name the
+// server chose (e.g. Stat(".") might otherwise be named after the root).
+type fsFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi fsFileInfo) Name() string {
+	return fi.name
+}
+
+// symlinkInfo hides the fs.ModeDir that FollowSymlinks sets on links to
+// directories.
+type symlinkInfo struct {
+	fs.FileInfo
+}
+
+func (fi symlinkInfo) Mode() fs.FileMode {
+	return fi.FileInfo.Mode() &^ fs.ModeDir
+}
+
+func (fi symlinkInfo) IsDir() bool {
+	return false
+}
+
+type fsFile struct {
+	name   string
+	info   fs.FileInfo
+	reader *io.PipeReader
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Read(buf []byte) (int, error) {
+	n, err := f.reader.Read(buf)
+	if err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	return n, err
+}
+
+// Close aborts the transfer if it is still in progress.
+func (f *fsFile) Close() error {
+	return f.reader.Close()
+}
+
+type fsDir struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	loaded  bool
+	offset  int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile. The listing is fetched from the server
+// on the first call.
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.loaded = true
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	for _, addr := range ftpdAddrs {
+		c, err := DialConfig(goftpConfig, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fsys := c.FS("")
+
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+
+		if !reflect.DeepEqual(names, []string{"git-ignored", "lorem.txt", "subdir"}) {
+			t.Errorf("got: %v", names)
+		}
+
+		contents, err := fs.ReadFile(fsys, "subdir/1234.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal([]byte{1, 2, 3, 4}, contents) {
+			t.Errorf("Got %v", contents)
+		}
+
+		// streaming through Open rather than ReadFile
+		f, err := fs.Sub(fsys, "subdir")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := f.Open("1234.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err = ioutil.ReadAll(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+
+		if !bytes.Equal([]byte{1, 2, 3, 4}, contents) {
+			t.Errorf("Got %v", contents)
+		}
+
+		info, err := fs.Stat(fsys, "subdir")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Name() != "subdir" || !info.IsDir() {
+			t.Errorf("got %s (dir=%v)", info.Name(), info.IsDir())
+		}
+
+		var walked []string
+		err = fs.WalkDir(fsys, "subdir", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			walked = append(walked, p)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(walked, []string{"subdir", "subdir/1234.bin"}) {
+			t.Errorf("got: %v", walked)
+		}
+
+		if _, err := fsys.Open("../escape"); err == nil {
+			t.Error("expected invalid path error")
+		}
+
+		if err := fstest.TestFS(fsys, "lorem.txt", "subdir/1234.bin"); err != nil {
+			t.Error(err)
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
+
+func TestFSSymlinkLoop(t *testing.T) {
+	c := newClient(Config{
+		FollowSymlinks: true,
+		StatListMode:   StatListAlways,
+		stubResponses: map[string]stubResponse{
+			"STAT -l top": {213, "Status of top:\n" +
+				" drwxr-xr-x   2 goftp    goftp        4096 Jul 28  2015 sub\n" +
+				" lrwxrwxrwx   1 goftp    goftp           2 Jul 28  2015 loop -> ..\n" +
+				" lrwxrwxrwx   1 goftp    goftp           3 Jul 28  2015 sibling -> sub\n" +
+				"End of status"},
+			"STAT -l top/sub": {213, "Status of top/sub:\nEnd of status"},
+			"MLST top":        {250, "Start of list for top\n type=dir;modify=20150728000000; top\nEnd of list"},
+			"MLST top/sub":    {250, "Start of list for top/sub\n type=dir;modify=20150728000000; sub\nEnd of list"},
+			"MLST .":          {250, "Start of list for .\n type=dir;modify=20150728000000; /\nEnd of list"},
+			"PWD":             {257, `"/" is the current directory`},
+		},
+	}, []hostAddr{{addr: "127.0.0.1:21"}})
+
+	pconn := &persistentConn{config: c.config, features: map[string]string{"MLST": ""}}
+	c.pinned = pconn
+	c.freeConnCh <- pconn
+
+	// the client resolves links to directories, except to an ancestor
+	infos, err := c.ReadDir("top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 || infos[1].IsDir() || !infos[2].IsDir() {
+		t.Fatalf("expected only sibling to be a directory: %v", infos)
+	}
+
+	var walked []string
+	err = fs.WalkDir(c.FS("top"), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, fmt.Sprintf("%s %s", p, d.Type()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []string{". d---------", "loop L---------", "sibling L---------", "sub d---------"}; !reflect.DeepEqual(walked, exp) {
+		t.Errorf("got %q", walked)
+	}
+}
+
+func TestFSStatWithoutMLST(t *testing.T) {
+	c := newClient(Config{
+		StatListMode: StatListAlways,
+		stubResponses: map[string]stubResponse{
+			"STAT -l top": {213, "Status of top:\n" +
+				" drwxr-xr-x   4 goftp    goftp        4096 Jul 28  2015 sub\n" +
+				"End of status"},
+			"STAT -l top/sub": {213, "Status of top/sub:\n" +
+				" drwxr-xr-x   2 goftp    goftp        4096 Jul 28  2015 a\n" +
+				" drwxr-xr-x   2 goftp    goftp        4096 Jul 28  2015 b\n" +
+				"End of status"},
+			"STAT -l top/sub/a": {213, "Status of top/sub/a:\nEnd of status"},
+			"STAT -l top/sub/b": {213, "Status of top/sub/b:\nEnd of status"},
+		},
+	}, []hostAddr{{addr: "127.0.0.1:21"}})
+
+	pconn := &persistentConn{config: c.config, features: map[string]string{}}
+	c.pinned = pconn
+	c.freeConnCh <- pconn
+
+	fsys := c.FS("top")
+
+	info, err := fs.Stat(fsys, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Name() != "sub" || !info.IsDir() {
+		t.Errorf("got %s (dir=%v)", info.Name(), info.IsDir())
+	}
+
+	if _, err := fs.Stat(fsys, "sub/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v", err)
+	}
+
+	if err := fstest.TestFS(fsys, "sub/a", "sub/b"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFSCloseAbortsTransfer(t *testing.T) {
+	controlAddr, accepted := serveGreetings(t)
+
+	// data connections send data until the client hangs up
+	data, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+
+	go func() {
+		for {
+			conn, err := data.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, zeroReader{})
+			}()
+		}
+	}()
+
+	port := data.Addr().(*net.TCPAddr).Port
+
+	c, err := DialConfig(Config{
+		DisableEPSV: true,
+		MLSTFacts:   []string{"type", "size", "modify"},
+		stubResponses: map[string]stubResponse{
+			"USER anonymous": {230, "Logged in"},
+			"FEAT":           {211, "Features:\n MLST type*;size*;modify*;\n REST STREAM\nEnd"},
+			"SYST":           {215, "UNIX Type: L8"},
+			"MLST big":       {250, "Listing big\n type=file;size=1000000000;modify=20150101000000; big\nEnd"},
+			"TYPE I":         {200, "Type set to I"},
+			"PASV":           {227, fmt.Sprintf("Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)},
+			"RETR big":       {150, "Opening BINARY mode data connection"},
+			"REST 10":        {350, "Restarting at 10"},
+		},
+	}, controlAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	f, err := c.FS("").Open("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.ReadFull(f, make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// resuming would need a new connection, since the aborted one is broken
+	time.Sleep(200 * time.Millisecond)
+
+	if n := atomic.LoadInt32(accepted); n != 1 {
+		t.Errorf("expected 1 control connection, got %d", n)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
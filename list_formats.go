@@ -0,0 +1,437 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file contains parsers for the non-Unix "LIST" formats parseLIST falls
+// back to when an entry doesn't look like "ls -l" output. See listFormats.
+
+func listMtimeError(err error, entry string) error {
+	return ftpError{err: fmt.Errorf(`failed parsing LIST entry's mtime: %s (%s)`, err, entry), kind: KindProtocol}
+}
+
+func listSizeError(err error, entry string) error {
+	return ftpError{err: fmt.Errorf(`failed parsing LIST entry's size: %s (%s)`, err, entry), kind: KindProtocol}
+}
+
+var dosListRegex = regexp.MustCompile(`^\s*(\d{2}-\d{2}-(?:\d{4}|\d{2}))\s+(\d{1,2}:\d{2})\s*([AaPp][Mm])?\s+(<DIR>|\d+)\s+(.+)$`)
+
+// Windows/IIS "DOS" style listings:
+// 02-16-15  08:41AM       <DIR>          pub
+// 02-16-15  08:41AM                 1234 lorem.txt
+// 02-16-2015  20:41                 1234 lorem.txt
+func parseDOSLIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	matches := dosListRegex.FindStringSubmatch(entry)
+	if matches == nil {
+		return nil, false, nil
+	}
+
+	var layout string
+	if len(matches[1]) == len("01-02-06") {
+		layout = "01-02-06"
+	} else {
+		layout = "01-02-2006"
+	}
+
+	clock := matches[2]
+	if matches[3] != "" {
+		layout += " 3:04PM"
+		clock += strings.ToUpper(matches[3])
+	} else {
+		layout += " 15:04"
+	}
+
+	mtime, err := time.ParseInLocation(layout, matches[1]+" "+clock, loc)
+	if err != nil {
+		return nil, true, listMtimeError(err, entry)
+	}
+
+	// no permissions in the listing, just say it's readable to us
+	mode := os.FileMode(0400)
+
+	var size int64
+	if matches[4] == "<DIR>" {
+		mode |= os.ModeDir
+	} else {
+		size, err = strconv.ParseInt(matches[4], 10, 64)
+		if err != nil {
+			return nil, true, listSizeError(err, entry)
+		}
+	}
+
+	return &ftpFile{
+		name:  matches[5],
+		size:  size,
+		mode:  mode,
+		mtime: mtime,
+		facts: &FileFacts{Raw: entry},
+	}, true, nil
+}
+
+// Easily Parsed LIST Format (http://cr.yp.to/ftp/list/eplf.html). Facts are
+// comma separated, followed by a tab and the name:
+// +i8388621.48594,m825718503,r,s280,	djb.html
+// +i8388621.50690,m824255907,/,	514
+func parseEPLF(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	if !strings.HasPrefix(entry, "+") {
+		return nil, false, nil
+	}
+
+	tab := strings.IndexByte(entry, '\t')
+	if tab == -1 || tab == len(entry)-1 {
+		return nil, true, ftpError{err: fmt.Errorf(`failed parsing EPLF entry: %s`, entry), kind: KindProtocol}
+	}
+
+	var (
+		info = &ftpFile{
+			name:  entry[tab+1:],
+			mode:  0400,
+			facts: &FileFacts{Raw: entry},
+		}
+		err error
+	)
+
+	for _, fact := range strings.Split(entry[1:tab], ",") {
+		if fact == "" {
+			continue
+		}
+
+		switch fact[0] {
+		case '/':
+			info.mode |= os.ModeDir
+		case 's':
+			info.size, err = strconv.ParseInt(fact[1:], 10, 64)
+			if err != nil {
+				return nil, true, listSizeError(err, entry)
+			}
+		case 'm':
+			// seconds since the epoch, so no need for loc
+			secs, err := strconv.ParseInt(fact[1:], 10, 64)
+			if err != nil {
+				return nil, true, listMtimeError(err, entry)
+			}
+			info.mtime = time.Unix(secs, 0).UTC()
+		case 'i':
+			info.facts.Unique = fact[1:]
+		case 'u':
+			if strings.HasPrefix(fact, "up") {
+				perm, err := strconv.ParseUint(fact[2:], 8, 32)
+				if err != nil {
+					return nil, true, ftpError{err: fmt.Errorf(`failed parsing EPLF permissions: %s (%s)`, err, entry), kind: KindProtocol}
+				}
+				info.mode = info.mode&os.ModeType | os.FileMode(perm)&os.ModePerm
+			}
+		}
+	}
+
+	return info, true, nil
+}
+
+var netWareListRegex = regexp.MustCompile(`^([d-])\s+\[([RWCEAFMS-]{8})\]\s+(\S+)\s+(\d+)\s+(\w{3}\s+\d{1,2})\s+(\d{1,2}:\d{2}|\d{4})\s+(.+)$`)
+
+// Novell NetWare:
+// d [R----F--] supervisor            512       Jan 16 18:53    login
+// - [R----F--] rhesus             214059       Oct 20 15:27    cx.exe
+func parseNetWareLIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	matches := netWareListRegex.FindStringSubmatch(entry)
+	if matches == nil {
+		return nil, false, nil
+	}
+
+	var mode os.FileMode
+	if matches[1] == "d" {
+		mode |= os.ModeDir
+	}
+
+	// trustee rights, which apply to us rather than the owner
+	rights := matches[2]
+	if strings.Contains(rights, "R") {
+		mode |= 0400
+	}
+	if strings.ContainsAny(rights, "WCE") {
+		mode |= 0200
+	}
+	if mode.IsDir() && strings.Contains(rights, "F") {
+		mode |= 0100
+	}
+
+	size, err := strconv.ParseInt(matches[4], 10, 64)
+	if err != nil {
+		return nil, true, listSizeError(err, entry)
+	}
+
+	mtime, err := parseLSTime(matches[5], matches[6], loc)
+	if err != nil {
+		return nil, true, listMtimeError(err, entry)
+	}
+
+	return &ftpFile{
+		name:  matches[7],
+		size:  size,
+		mode:  mode,
+		mtime: mtime,
+		facts: &FileFacts{Raw: entry, Owner: matches[3]},
+	}, true, nil
+}
+
+var os400ListRegex = regexp.MustCompile(`^(?:(\S+)\s+(\d+)\s+(\d{2}([/.])\d{2}[/.]\d{2}(?:\d{2})?)\s+(\d{2}:\d{2}:\d{2})\s+|\s+)(\*[A-Z]+)\s+(.+)$`)
+
+// IBM OS/400 (IBM i). Members of physical files are listed without owner,
+// size or date:
+//
+//	QSYS           77824 02/23/00 15:09:55 *DIR       QDLS/
+//	QSECOFR            0 12/31/99 00:00:00 *STMF      BOO.TXT
+//	QPGMR         135168 04/17/98 17:11:17 *FILE      TEST.SAVF
+//	                                       *MEM       TEST.SAVF/TEST.MBR
+func parseOS400LIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	matches := os400ListRegex.FindStringSubmatch(entry)
+	if matches == nil {
+		return nil, false, nil
+	}
+
+	info := &ftpFile{
+		name:  path.Base(strings.TrimSuffix(matches[7], "/")),
+		mode:  0400,
+		facts: &FileFacts{Raw: entry, Owner: matches[1], Type: matches[6]},
+	}
+
+	switch matches[6] {
+	case "*DIR", "*DDIR", "*FLR", "*LIB":
+		info.mode |= os.ModeDir
+	}
+
+	if matches[1] == "" {
+		return info, true, nil
+	}
+
+	var err error
+	info.size, err = strconv.ParseInt(matches[2], 10, 64)
+	if err != nil {
+		return nil, true, listSizeError(err, entry)
+	}
+
+	// the date format depends on the job's locale, but "/" usually means US
+	// style and "." European style
+	layout := "01/02/06"
+	if matches[4] == "." {
+		layout = "02.01.06"
+	}
+	if len(matches[3]) == len("01/02/2006") {
+		layout = layout[:6] + "2006"
+	}
+
+	info.mtime, err = time.ParseInLocation(layout+" 15:04:05", matches[3]+" "+matches[5], loc)
+	if err != nil {
+		return nil, true, listMtimeError(err, entry)
+	}
+
+	return info, true, nil
+}
+
+var (
+	vmsListRegex   = regexp.MustCompile(`^(\S+);(\d+)\s+(\d+)(?:/(\d+))?\s+(\d{1,2}-[A-Za-z]{3}-\d{4})\s+(\d{1,2}:\d{2}(?::\d{2}(?:\.\d+)?)?)(?:\s+\[([^\]]*)\])?(?:\s+\(([^)]*)\))?\s*$`)
+	vmsHeaderRegex = regexp.MustCompile(`^(?:Directory \S+|Total of \d+ files?.*)$`)
+)
+
+// Size of an OpenVMS disk block.
+const vmsBlockSize = 512
+
+// OpenVMS. Sizes are in blocks (used/allocated), names carry a version
+// number, and directories are files ending in ".DIR":
+// Directory USERS:[ANONYMOUS]
+// CII-MANUAL.TEX;1  213/216  29-JAN-1996 03:33:12  [ANONYMOU,ANONYMOUS]   (RWED,RWED,,)
+// PUB.DIR;1           1/3    29-JAN-1996 03:33:12  [SYSTEM]   (RWE,RWE,RE,RE)
+// Total of 2 files, 214/219 blocks.
+//
+// Entries whose name is too long are wrapped onto two lines by some servers;
+// those aren't supported.
+func parseVMSLIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	if vmsHeaderRegex.MatchString(entry) {
+		return nil, true, nil
+	}
+
+	matches := vmsListRegex.FindStringSubmatch(entry)
+	if matches == nil {
+		return nil, false, nil
+	}
+
+	info := &ftpFile{
+		name: matches[1],
+		facts: &FileFacts{
+			Raw: entry,
+			Extra: map[string]string{
+				"version": matches[2],
+				"blocks":  matches[3],
+			},
+		},
+	}
+
+	if matches[4] != "" {
+		info.facts.Extra["allocated"] = matches[4]
+	}
+
+	if strings.HasSuffix(strings.ToUpper(info.name), ".DIR") {
+		info.name = info.name[:len(info.name)-len(".DIR")]
+		info.mode |= os.ModeDir
+	}
+
+	blocks, err := strconv.ParseInt(matches[3], 10, 64)
+	if err != nil {
+		return nil, true, listSizeError(err, entry)
+	}
+	info.size = blocks * vmsBlockSize
+
+	clock := matches[6]
+	if dot := strings.IndexByte(clock, '.'); dot != -1 {
+		clock = clock[:dot]
+	}
+	layout := "2-Jan-2006 15:04"
+	if strings.Count(clock, ":") == 2 {
+		layout += ":05"
+	}
+
+	info.mtime, err = time.ParseInLocation(layout, matches[5]+" "+clock, loc)
+	if err != nil {
+		return nil, true, listMtimeError(err, entry)
+	}
+
+	// [GROUP,OWNER] or just [OWNER]
+	if owner := matches[7]; owner != "" {
+		if comma := strings.IndexByte(owner, ','); comma != -1 {
+			info.facts.Group = owner[:comma]
+			info.facts.Owner = owner[comma+1:]
+		} else {
+			info.facts.Owner = owner
+		}
+	}
+
+	// (system,owner,group,world)
+	if perms := strings.Split(matches[8], ","); len(perms) == 4 {
+		for i, class := range perms[1:] {
+			shift := uint(3 * (2 - i))
+			if strings.Contains(class, "R") {
+				info.mode |= os.FileMode(04 << shift)
+			}
+			if strings.Contains(class, "W") {
+				info.mode |= os.FileMode(02 << shift)
+			}
+			if strings.Contains(class, "E") {
+				info.mode |= os.FileMode(01 << shift)
+			}
+		}
+	} else {
+		info.mode |= 0400
+	}
+
+	return info, true, nil
+}
+
+var (
+	mvsDatasetRegex = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\d{4}/\d{2}/\d{2}|\*\*NONE\*\*)\s+(\d+)\s+(\d+)\s+(\S+)\s+(\d+)\s+(\d+)\s+(\S+)\s+(\S+)$`)
+	mvsSpecialRegex = regexp.MustCompile(`^(Migrated|Pseudo Directory)\s+(\S+)$`)
+	mvsMemberRegex  = regexp.MustCompile(`^\s*(\S+)\s+(\d{2}\.\d{2})\s+(\d{4}/\d{2}/\d{2})\s+(\d{4}/\d{2}/\d{2})\s+(\d{2}:\d{2}(?::\d{2})?)\s+(\d+)\s+(\d+)\s+(\d+)\s+(\S+)$`)
+	mvsHeaderRegex  = regexp.MustCompile(`^(?:Volume\s+Unit\s+Referred|\s*Name\s+VV\.MM\s+Created)`)
+)
+
+// IBM z/OS MVS. Datasets are listed with their attributes; partitioned
+// datasets (Dsorg PO) contain members, so they are reported as directories.
+// Sizes aren't listed in bytes, so they are left as 0.
+//
+//	Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname
+//	WYOSPT 3420   2003/07/15  1  128  FB      80  3120  PS  ABC.DEF
+//	WYOSPT 3390   **NONE**    1    1  FB      80  6160  PO  PDS.NAME
+//	Migrated                                                HSM.FILE
+//
+// Listing a partitioned dataset shows its members:
+//
+//	 Name     VV.MM   Created       Changed      Size  Init   Mod   Id
+//	MEMBER1   01.01 2002/09/12 2002/09/12 10:30    10    10     0 USER1
+func parseMVSLIST(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+	if mvsHeaderRegex.MatchString(entry) {
+		return nil, true, nil
+	}
+
+	if matches := mvsSpecialRegex.FindStringSubmatch(entry); matches != nil {
+		info := &ftpFile{
+			name:  matches[2],
+			mode:  0400,
+			facts: &FileFacts{Raw: entry},
+		}
+		if matches[1] == "Pseudo Directory" {
+			info.mode |= os.ModeDir
+		}
+		return info, true, nil
+	}
+
+	if matches := mvsMemberRegex.FindStringSubmatch(entry); matches != nil {
+		mtime, err := time.ParseInLocation("2006/01/02 15:04", matches[4]+" "+matches[5][:5], loc)
+		if err != nil {
+			return nil, true, listMtimeError(err, entry)
+		}
+
+		created, _ := time.ParseInLocation("2006/01/02", matches[3], loc)
+
+		return &ftpFile{
+			name:  matches[1],
+			mode:  0400,
+			mtime: mtime,
+			facts: &FileFacts{
+				Raw:    entry,
+				Owner:  matches[9],
+				Create: created,
+				Extra: map[string]string{
+					"version": matches[2],
+					"records": matches[6],
+				},
+			},
+		}, true, nil
+	}
+
+	matches := mvsDatasetRegex.FindStringSubmatch(entry)
+	if matches == nil {
+		return nil, false, nil
+	}
+
+	info := &ftpFile{
+		name: matches[10],
+		mode: 0400,
+		facts: &FileFacts{
+			Raw:  entry,
+			Type: matches[9],
+			Extra: map[string]string{
+				"volume":  matches[1],
+				"unit":    matches[2],
+				"extents": matches[4],
+				"used":    matches[5],
+				"recfm":   matches[6],
+				"lrecl":   matches[7],
+				"blksize": matches[8],
+			},
+		},
+	}
+
+	if strings.HasPrefix(matches[9], "PO") {
+		info.mode |= os.ModeDir
+	}
+
+	if matches[3] != "**NONE**" {
+		var err error
+		info.mtime, err = time.ParseInLocation("2006/01/02", matches[3], loc)
+		if err != nil {
+			return nil, true, listMtimeError(err, entry)
+		}
+	}
+
+	return info, true, nil
+}
@@ -0,0 +1,301 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listCase struct {
+	raw   string
+	name  string
+	size  int64
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func checkListCases(t *testing.T, cases []listCase) {
+	for _, c := range cases {
+		info, err := parseLIST(c.raw, time.UTC, false)
+		if err != nil {
+			t.Errorf("%s: %s", c.raw, err)
+			continue
+		}
+
+		if info.Name() != c.name || info.Size() != c.size || info.Mode() != c.mode || !info.ModTime().Equal(c.mtime) {
+			t.Errorf("%s:\n exp %s %d %s %s\n got %s %d %s %s", c.raw,
+				c.name, c.size, c.mode, c.mtime,
+				info.Name(), info.Size(), info.Mode(), info.ModTime(),
+			)
+		}
+
+		if info.Sys().(*FileFacts).Raw != c.raw {
+			t.Errorf("%s: missing raw entry", c.raw)
+		}
+	}
+}
+
+func TestParseLISTDOS(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"02-16-15  08:41AM       <DIR>          pub",
+			"pub", 0, os.ModeDir | 0400,
+			time.Date(2015, 2, 16, 8, 41, 0, 0, time.UTC),
+		},
+		{
+			"02-16-15  08:41PM                 1234 lorem ipsum.txt",
+			"lorem ipsum.txt", 1234, 0400,
+			time.Date(2015, 2, 16, 20, 41, 0, 0, time.UTC),
+		},
+		{
+			"12-01-98  12:05AM                   10 old.txt",
+			"old.txt", 10, 0400,
+			time.Date(1998, 12, 1, 0, 5, 0, 0, time.UTC),
+		},
+		{
+			"02-16-2015  20:41                 1234 lorem.txt",
+			"lorem.txt", 1234, 0400,
+			time.Date(2015, 2, 16, 20, 41, 0, 0, time.UTC),
+		},
+		{
+			"02-16-2015  7:03  <DIR>  sub dir",
+			"sub dir", 0, os.ModeDir | 0400,
+			time.Date(2015, 2, 16, 7, 3, 0, 0, time.UTC),
+		},
+	})
+
+	info, err := parseLIST("02-16-15  08:41AM       <DIR>          ..", time.UTC, true)
+	if info != nil || err != nil {
+		t.Errorf("expected parent dir to be skipped, got %v, %v", info, err)
+	}
+
+	if _, err := parseLIST("02-16-15 garbage", time.UTC, false); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestParseLISTEPLF(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"+i8388621.48594,m825718503,r,s280,\tdjb.html",
+			"djb.html", 280, 0400,
+			time.Unix(825718503, 0),
+		},
+		{
+			"+i8388621.50690,m824255907,/,\t514",
+			"514", 0, os.ModeDir | 0400,
+			time.Unix(824255907, 0),
+		},
+		{
+			"+m824255907,/,up755,\tpub",
+			"pub", 0, os.ModeDir | 0755,
+			time.Unix(824255907, 0),
+		},
+	})
+
+	info, err := parseLIST("+i8388621.48594,m825718503,r,s280,\tdjb.html", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unique := info.Sys().(*FileFacts).Unique; unique != "8388621.48594" {
+		t.Errorf("got unique %q", unique)
+	}
+
+	if _, err := parseLIST("+m825718503,r,s280,", time.UTC, false); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestParseLISTNetWare(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"d [R----F--] supervisor            512       Jan 16  2014    login",
+			"login", 512, os.ModeDir | 0500,
+			time.Date(2014, 1, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"- [RWCEAFMS] rhesus             214059       Oct 20  2013    cx.exe",
+			"cx.exe", 214059, 0600,
+			time.Date(2013, 10, 20, 0, 0, 0, 0, time.UTC),
+		},
+	})
+}
+
+func TestParseLISTOS400(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"QSYS           77824 02/23/00 15:09:55 *DIR       QDLS/",
+			"QDLS", 77824, os.ModeDir | 0400,
+			time.Date(2000, 2, 23, 15, 9, 55, 0, time.UTC),
+		},
+		{
+			"QSECOFR            0 31.12.99 00:00:00 *STMF      BOO.TXT",
+			"BOO.TXT", 0, 0400,
+			time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"QPGMR         135168 04/17/98 17:11:17 *FILE      TEST.SAVF",
+			"TEST.SAVF", 135168, 0400,
+			time.Date(1998, 4, 17, 17, 11, 17, 0, time.UTC),
+		},
+		{
+			"                                       *MEM       TEST.SAVF/TEST.MBR",
+			"TEST.MBR", 0, 0400,
+			time.Time{},
+		},
+	})
+}
+
+func TestParseLISTVMS(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"CII-MANUAL.TEX;1  213/216  29-JAN-1996 03:33:12  [ANONYMOU,ANONYMOUS]   (RWED,RWED,,)",
+			"CII-MANUAL.TEX", 213 * 512, 0700,
+			time.Date(1996, 1, 29, 3, 33, 12, 0, time.UTC),
+		},
+		{
+			"PUB.DIR;1           1/3    29-JAN-1996 03:33  [SYSTEM]   (RWE,RWE,RE,RE)",
+			"PUB", 512, os.ModeDir | 0755,
+			time.Date(1996, 1, 29, 3, 33, 0, 0, time.UTC),
+		},
+	})
+
+	info, err := parseLIST("CII-MANUAL.TEX;1  213/216  29-JAN-1996 03:33:12  [ANONYMOU,ANONYMOUS]   (RWED,RWED,,)", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := info.Sys().(*FileFacts)
+	if facts.Owner != "ANONYMOUS" || facts.Group != "ANONYMOU" || facts.Extra["version"] != "1" || facts.Extra["allocated"] != "216" {
+		t.Errorf("got facts %+v", facts)
+	}
+
+	for _, header := range []string{"Directory USERS:[ANONYMOUS]", "Total of 2 files, 214/219 blocks."} {
+		info, err := parseLIST(header, time.UTC, false)
+		if info != nil || err != nil {
+			t.Errorf("expected %q to be skipped, got %v, %v", header, info, err)
+		}
+	}
+}
+
+func TestParseLISTMVS(t *testing.T) {
+	checkListCases(t, []listCase{
+		{
+			"WYOSPT 3420   2003/07/15  1  128  FB      80  3120  PS  ABC.DEF",
+			"ABC.DEF", 0, 0400,
+			time.Date(2003, 7, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"WYOSPT 3390   **NONE**    1    1  FB      80  6160  PO  PDS.NAME",
+			"PDS.NAME", 0, os.ModeDir | 0400,
+			time.Time{},
+		},
+		{
+			"Migrated                                                HSM.FILE",
+			"HSM.FILE", 0, 0400,
+			time.Time{},
+		},
+		{
+			"MEMBER1   01.01 2002/09/12 2002/09/13 10:30    10    10     0 USER1",
+			"MEMBER1", 0, 0400,
+			time.Date(2002, 9, 13, 10, 30, 0, 0, time.UTC),
+		},
+	})
+
+	info, err := parseLIST("MEMBER1   01.01 2002/09/12 2002/09/13 10:30    10    10     0 USER1", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := info.Sys().(*FileFacts)
+	if facts.Owner != "USER1" || !facts.Create.Equal(time.Date(2002, 9, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("got facts %+v", facts)
+	}
+
+	for _, header := range []string{
+		"Volume Unit    Referred Ext Used Recfm Lrecl BlkSz Dsorg Dsname",
+		" Name     VV.MM   Created       Changed      Size  Init   Mod   Id",
+	} {
+		info, err := parseLIST(header, time.UTC, false)
+		if info != nil || err != nil {
+			t.Errorf("expected %q to be skipped, got %v, %v", header, info, err)
+		}
+	}
+}
+
+func TestParseLISTCustomParsers(t *testing.T) {
+	// "name|size" entries, with "#" comments
+	pipeParser := ListParserFunc(func(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+		if strings.HasPrefix(entry, "#") {
+			return nil, true, nil
+		}
+
+		bar := strings.IndexByte(entry, '|')
+		if bar == -1 {
+			return nil, false, nil
+		}
+
+		size, err := strconv.ParseInt(entry[bar+1:], 10, 64)
+		if err != nil {
+			return nil, true, err
+		}
+
+		return &ftpFile{name: entry[:bar], size: size, facts: &FileFacts{Raw: entry}}, false, nil
+	})
+
+	// claims every entry
+	everythingParser := ListParserFunc(func(entry string, loc *time.Location) (os.FileInfo, bool, error) {
+		return &ftpFile{name: "everything", facts: &FileFacts{Raw: entry}}, false, nil
+	})
+
+	const unixEntry = "-rw-r--r--   1 goftp    goftp           4 Jul 28  2015 1234.bin"
+
+	c := newClient(Config{FallbackListParsers: []ListParser{pipeParser, everythingParser}}, nil)
+
+	info, err := parseLISTWith(c.listParsers(&persistentConn{}), "foo.txt|123", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "foo.txt" || info.Size() != 123 {
+		t.Errorf("got %s (%d bytes)", info.Name(), info.Size())
+	}
+
+	info, err = parseLISTWith(c.listParsers(&persistentConn{}), "# comment", time.UTC, false)
+	if info != nil || err != nil {
+		t.Errorf("expected comment to be skipped, got %v, %v", info, err)
+	}
+
+	if _, err := parseLISTWith(c.listParsers(&persistentConn{}), "foo.txt|abc", time.UTC, false); err == nil {
+		t.Error("expected error")
+	}
+
+	// built-in formats take precedence over fallbacks
+	info, err = parseLISTWith(c.listParsers(&persistentConn{}), unixEntry, time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "1234.bin" {
+		t.Errorf("got %s", info.Name())
+	}
+
+	c = newClient(Config{ListParsers: []ListParser{everythingParser}}, nil)
+
+	info, err = parseLISTWith(c.listParsers(&persistentConn{}), unixEntry, time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "everything" {
+		t.Errorf("got %s", info.Name())
+	}
+
+	if _, err := parseLIST("foo.txt|123", time.UTC, false); err == nil {
+		t.Error("expected error without custom parsers")
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverLocations remembers the time zones detected for each host, and how
+// often detection failed. See Config.DetectServerLocation.
+type serverLocations struct {
+	mu       sync.Mutex
+	locs     map[string]*time.Location
+	failures map[string]int
+}
+
+func newServerLocations() *serverLocations {
+	return &serverLocations{
+		locs:     make(map[string]*time.Location),
+		failures: make(map[string]int),
+	}
+}
+
+func (sl *serverLocations) get(host string) (*time.Location, bool) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	loc, found := sl.locs[host]
+	return loc, found
+}
+
+func (sl *serverLocations) put(host string, loc *time.Location) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.locs[host] = loc
+}
+
+// Record a listing on "host" that didn't reveal its time zone. Returns the
+// number of failures so far.
+func (sl *serverLocations) fail(host string) int {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.failures[host]++
+	return sl.failures[host]
+}
+
+// Whether detection should still be tried on "host".
+func (sl *serverLocations) pending(host string) bool {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	_, found := sl.locs[host]
+	return !found && sl.failures[host] < maxLocationAttempts
+}
+
+// How many files of a listing to try "MDTM" on before giving up until the
+// next listing.
+const maxLocationProbes = 3
+
+// How many listings to try detecting a host's time zone in before giving up
+// and sticking with ServerLocation.
+const maxLocationAttempts = 3
+
+// Servers' UTC offsets are within this range.
+const maxLocationOffset = 14 * time.Hour
+
+// The time zone to parse "LIST" mtimes from pconn's server in.
+func (c *Client) serverLocation(pconn *persistentConn) *time.Location {
+	if loc, found := c.locations.get(pconn.host); found {
+		return loc
+	}
+	return c.config.ServerLocation
+}
+
+// Whether the time zone of pconn's server should be detected before parsing
+// its "LIST" output.
+func (c *Client) needsLocation(pconn *persistentConn) bool {
+	if !c.config.DetectServerLocation || !pconn.hasFeature("MDTM") {
+		return false
+	}
+
+	return c.locations.pending(pconn.host)
+}
+
+// Try to detect the time zone of pconn's server using "LIST" entries of
+// files in directory "dir".
+func (c *Client) detectLocation(pconn *persistentConn, parsers []ListParser, dir string, entries []string) error {
+	var probes int
+	for _, entry := range entries {
+		if probes == maxLocationProbes {
+			break
+		}
+
+		info, err := parseLISTWith(parsers, entry, time.UTC, true)
+		if err != nil || info == nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		// entries for old files usually only show the date
+		listed := info.ModTime()
+		if listed.Hour() == 0 && listed.Minute() == 0 {
+			continue
+		}
+
+		probes++
+
+		found, err := c.detectLocationFrom(pconn, path.Join(dir, info.Name()), listed)
+		if err != nil || found {
+			return err
+		}
+	}
+
+	if failures := c.locations.fail(pconn.host); failures == maxLocationAttempts {
+		pconn.debug("giving up detecting server time zone, using %s", c.config.ServerLocation)
+	}
+
+	return nil
+}
+
+// Compare "listed", the "LIST" mtime of file "p" parsed as UTC, with the
+// "MDTM" reply for it, which is always in UTC (RFC 3659 section 2.3). The
+// difference is the server's UTC offset.
+func (c *Client) detectLocationFrom(pconn *persistentConn, p string, listed time.Time) (bool, error) {
+	code, msg, err := pconn.sendCommand("MDTM %s", p)
+	if err != nil {
+		return false, err
+	}
+
+	if code != replyFileStatus {
+		pconn.debug("unexpected MDTM response: %d-%s", code, msg)
+		return false, nil
+	}
+
+	mtime, ok := new(mlstParser).parseModTime(strings.TrimSpace(msg))
+	if !ok {
+		pconn.debug("failed parsing MDTM response: %s", msg)
+		return false, nil
+	}
+
+	// "LIST" entries have at most minute precision
+	offset := listed.Sub(mtime.Truncate(time.Minute)).Round(15 * time.Minute)
+	if offset < -maxLocationOffset || offset > maxLocationOffset {
+		pconn.debug("ignoring implausible UTC offset %s for %s", offset, p)
+		return false, nil
+	}
+
+	loc := time.FixedZone(offsetName(offset), int(offset/time.Second))
+	c.locations.put(pconn.host, loc)
+
+	pconn.debug("detected server time zone %s", loc)
+
+	return true, nil
+}
+
+// Name a fixed time zone, e.g. "UTC+05:30".
+func offsetName(offset time.Duration) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+
+	return fmt.Sprintf("UTC%c%02d:%02d", sign, int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
+
+// Run "LIST" on "path" and detect the server's time zone from the listing,
+// returning its entries.
+func (c *Client) listDetectLocation(pconn *persistentConn, parsers []ListParser, all bool, path string) ([]string, error) {
+	var entries []string
+	err := c.listFunc(pconn, func(entry string) error {
+		entries = append(entries, entry)
+		return nil
+	}, all, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.detectLocation(pconn, parsers, path, entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
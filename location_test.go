@@ -0,0 +1,125 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDetectServerLocation(t *testing.T) {
+	config := Config{
+		StatListMode:         StatListAlways,
+		DetectServerLocation: true,
+		stubResponses: map[string]stubResponse{
+			"STAT -l dir": {213, "Status of dir:\n" +
+				"-rw-r--r--   1 goftp    goftp           4 Jul 28  2015 old.txt\n" +
+				"-rw-r--r--   1 goftp    goftp           4 Feb 16  2015 missing.txt\n" +
+				"drwxr-xr-x   2 goftp    goftp        4096 Feb 16 08:41 subdir\n" +
+				"02-16-15  08:41AM                 1234 lorem.txt\n" +
+				"End of status"},
+			"MDTM dir/lorem.txt": {213, "20150216064100"},
+			"STAT -l dir/lorem.txt": {213, "Status of dir/lorem.txt:\n" +
+				"02-16-15  08:41AM                 1234 lorem.txt\n" +
+				"End of status"},
+		},
+	}
+
+	c := newClient(config, []hostAddr{{addr: "127.0.0.1:21"}})
+	pconn := &persistentConn{
+		config:   c.config,
+		features: map[string]string{"MDTM": ""},
+		host:     "127.0.0.1:21",
+	}
+
+	var lorem os.FileInfo
+	err := c.listDir(pconn, false, "dir", func(info os.FileInfo) error {
+		if info.Name() == "lorem.txt" {
+			lorem = info
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Date(2015, 2, 16, 6, 41, 0, 0, time.UTC)
+	if lorem == nil || !lorem.ModTime().Equal(exp) {
+		t.Fatalf("got %v", lorem)
+	}
+
+	if loc := c.serverLocation(pconn); loc.String() != "UTC+02:00" {
+		t.Errorf("got %s", loc)
+	}
+
+	// the detected offset is reused without another MDTM
+	delete(c.config.stubResponses, "MDTM dir/lorem.txt")
+	pconn.config = c.config
+
+	info, err := c.stat(pconn, "dir/lorem.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.ModTime().Equal(exp) {
+		t.Errorf("got %s", info.ModTime())
+	}
+}
+
+func TestDetectServerLocationGivesUp(t *testing.T) {
+	config := Config{
+		StatListMode:         StatListAlways,
+		DetectServerLocation: true,
+		stubResponses: map[string]stubResponse{
+			"STAT -l dir": {213, "Status of dir:\n" +
+				"-rw-r--r--   1 goftp    goftp           4 Feb 16 08:41 lorem.txt\n" +
+				"End of status"},
+			// a day off
+			"MDTM dir/lorem.txt": {213, "20150215084100"},
+		},
+	}
+
+	c := newClient(config, []hostAddr{{addr: "127.0.0.1:21"}})
+	pconn := &persistentConn{
+		config:   c.config,
+		features: map[string]string{"MDTM": ""},
+		host:     "127.0.0.1:21",
+	}
+
+	for i := 0; i < maxLocationAttempts; i++ {
+		if !c.needsLocation(pconn) {
+			t.Fatalf("gave up after %d listings", i)
+		}
+
+		if err := c.listDir(pconn, false, "dir", func(os.FileInfo) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c.needsLocation(pconn) {
+		t.Error("expected detection to give up")
+	}
+
+	if loc := c.serverLocation(pconn); loc != time.UTC {
+		t.Errorf("got %s", loc)
+	}
+}
+
+func TestOffsetName(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                             "UTC+00:00",
+		2 * time.Hour:                 "UTC+02:00",
+		-5 * time.Hour:                "UTC-05:00",
+		5*time.Hour + 30*time.Minute:  "UTC+05:30",
+		-9*time.Hour - 30*time.Minute: "UTC-09:30",
+	}
+
+	for offset, exp := range cases {
+		if got := offsetName(offset); got != exp {
+			t.Errorf("%s: exp %s, got %s", offset, exp, got)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package goftp
 import (
 	"bufio"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/textproto"
@@ -33,6 +34,10 @@ type RawConn interface {
 
 	// Close the control and data connection, if open.
 	Close() error
+
+	// Whether the connection negotiated TLS (see Config.TLSPolicy). It stays
+	// true after Config.ClearControlChannel clears the control connection.
+	TLSNegotiated() bool
 }
 
 // Represents a single connection to an FTP server.
@@ -60,6 +65,9 @@ type persistentConn struct {
 	// map of ftp features available on server
 	features map[string]string
 
+	// server's reply to "SYST", or empty if not supported
+	system string
+
 	// remember EPSV support
 	epsvNotSupported bool
 
@@ -67,6 +75,15 @@ type persistentConn struct {
 	currentType string
 
 	host string
+
+	// whether TLS was negotiated on the control connection
+	tlsNegotiated bool
+
+	// hostname "host" was resolved from (or its IP), for TLS verification
+	serverName string
+
+	// last command sent (password redacted), for error context
+	lastCmd string
 }
 
 func (pconn *persistentConn) SendCommand(f string, args ...interface{}) (int, string, error) {
@@ -85,6 +102,15 @@ func (pconn *persistentConn) Close() error {
 	return pconn.close()
 }
 
+func (pconn *persistentConn) TLSNegotiated() bool {
+	return pconn.tlsNegotiated
+}
+
+// The entry of the Client's host list pconn was opened for.
+func (pconn *persistentConn) hostAddr() hostAddr {
+	return hostAddr{addr: pconn.host, serverName: pconn.serverName}
+}
+
 func (pconn *persistentConn) setControlConn(conn net.Conn) {
 	pconn.controlConn = conn
 	pconn.reader = textproto.NewReader(bufio.NewReader(conn))
@@ -122,7 +148,7 @@ func (pconn *persistentConn) sendCommandExpected(expected int, f string, args ..
 	}
 
 	if !ok {
-		return ftpError{code: code, msg: msg}
+		return pconn.replyError(code, msg)
 	}
 
 	return nil
@@ -136,6 +162,8 @@ func (pconn *persistentConn) sendCommand(f string, args ...interface{}) (int, st
 		logName = "PASS ******"
 	}
 
+	pconn.lastCmd = logName
+
 	pconn.debug("sending command %s", logName)
 
 	if pconn.config.stubResponses != nil {
@@ -151,10 +179,11 @@ func (pconn *persistentConn) sendCommand(f string, args ...interface{}) (int, st
 	if err != nil {
 		pconn.broken = true
 		pconn.debug(`error sending command "%s": %s`, logName, err)
-		return 0, "", ftpError{
+		return 0, "", pconn.withContext(ftpError{
 			err:       fmt.Errorf("error writing command: %s", err),
 			temporary: true,
-		}
+			timeout:   isTimeout(err),
+		})
 	}
 
 	code, msg, err := pconn.readResponse()
@@ -167,16 +196,42 @@ func (pconn *persistentConn) sendCommand(f string, args ...interface{}) (int, st
 	return code, msg, err
 }
 
+// Record the last command, host and connection on "err".
+func (pconn *persistentConn) withContext(err ftpError) ftpError {
+	err.cmd, err.arg = pconn.lastCmd, ""
+	if space := strings.IndexByte(pconn.lastCmd, ' '); space != -1 {
+		err.cmd, err.arg = pconn.lastCmd[:space], pconn.lastCmd[space+1:]
+	}
+	err.host = pconn.host
+	err.connIdx = pconn.idx
+	return err
+}
+
+// Add pconn's context to "err" if it is an ftpError that doesn't have any
+// yet, e.g. from parsing a listing.
+func (pconn *persistentConn) addContext(err error) error {
+	if fe, ok := err.(ftpError); ok && fe.host == "" {
+		return pconn.withContext(fe)
+	}
+	return err
+}
+
+// An error for an unexpected reply to the last command.
+func (pconn *persistentConn) replyError(code int, msg string) ftpError {
+	return pconn.withContext(ftpError{code: code, msg: msg})
+}
+
 func (pconn *persistentConn) readResponse() (int, string, error) {
 	pconn.controlConn.SetReadDeadline(time.Now().Add(pconn.config.Timeout))
 	code, msg, err := pconn.reader.ReadResponse(0)
 	if err != nil {
 		pconn.broken = true
 		pconn.debug("error reading response: %s", err)
-		err = ftpError{
+		err = pconn.withContext(ftpError{
 			err:       fmt.Errorf("error reading response: %s", err),
 			temporary: true,
-		}
+			timeout:   isTimeout(err),
+		})
 	}
 	return code, msg, err
 }
@@ -218,6 +273,127 @@ func (pconn *persistentConn) fetchFeatures() error {
 	return nil
 }
 
+func (pconn *persistentConn) fetchSystem() error {
+	code, msg, err := pconn.sendCommand("SYST")
+	if err != nil {
+		return err
+	}
+
+	if code != replySystemType {
+		pconn.debug("server doesn't support SYST: %d-%s", code, msg)
+		return nil
+	}
+
+	pconn.system = msg
+
+	return nil
+}
+
+// The built-in "LIST" format to try first for the server's "SYST" reply, or
+// nil for Unix-like (or unknown) servers.
+func (pconn *persistentConn) systemListFormat() ListParser {
+	fields := strings.Fields(pconn.system)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return systemListFormats[strings.ToUpper(fields[0])]
+}
+
+// Built-in "LIST" formats of non-Unix servers, keyed by the first word of
+// their "SYST" reply, e.g. "MVS is the operating system of this server".
+var systemListFormats = map[string]ListParser{
+	"WINDOWS_NT": ListParserFunc(parseDOSLIST),
+	"NETWARE":    ListParserFunc(parseNetWareLIST),
+	"OS/400":     ListParserFunc(parseOS400LIST),
+	"VMS":        ListParserFunc(parseVMSLIST),
+	"MVS":        ListParserFunc(parseMVSLIST),
+}
+
+// Enable the facts in Config.MLSTFacts the server offers, if they aren't
+// already. "OPTS MLST" replaces the set of enabled facts, so facts the server
+// enables by default are kept in the list. See RFC 3659 section 7.9.
+func (pconn *persistentConn) negotiateMLST() error {
+	val, found := pconn.features["MLST"]
+	if !found || val == "" {
+		return nil
+	}
+
+	available, enabled := parseMLSTFeature(val)
+
+	var (
+		want    []string
+		changed bool
+	)
+	for _, fact := range available {
+		isEnabled := enabled[strings.ToLower(fact)]
+
+		wanted := false
+		for _, w := range pconn.config.MLSTFacts {
+			if strings.EqualFold(fact, w) {
+				wanted = true
+				break
+			}
+		}
+
+		if isEnabled || wanted {
+			want = append(want, fact)
+			changed = changed || !isEnabled
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	factList := strings.Join(want, ";") + ";"
+
+	code, msg, err := pconn.sendCommand("OPTS MLST %s", factList)
+	if err != nil {
+		return err
+	}
+
+	if !positiveCompletionReply(code) {
+		pconn.debug("server rejected OPTS MLST: %d-%s", code, msg)
+		return nil
+	}
+
+	// keep the feature value in sync with what's enabled now
+	var feature string
+	for _, fact := range available {
+		if containsString(want, fact) {
+			fact += "*"
+		}
+		feature += fact + ";"
+	}
+	pconn.features["MLST"] = feature
+
+	return nil
+}
+
+// Parse the value of the MLST feature, e.g. "type*;size*;modify*;perm;", into
+// the facts the server supports and (lowercased) which of them are enabled.
+func parseMLSTFeature(val string) ([]string, map[string]bool) {
+	var available []string
+	enabled := make(map[string]bool)
+
+	for _, fact := range strings.Split(val, ";") {
+		fact = strings.TrimSpace(fact)
+		if fact == "" {
+			continue
+		}
+
+		if strings.HasSuffix(fact, "*") {
+			fact = fact[:len(fact)-1]
+			enabled[strings.ToLower(fact)] = true
+		}
+
+		available = append(available, fact)
+	}
+
+	return available, enabled
+}
+
 func (pconn *persistentConn) hasFeature(name string) bool {
 	_, found := pconn.features[name]
 	return found
@@ -247,12 +423,26 @@ func (pconn *persistentConn) logIn() error {
 	}
 
 	if !positiveCompletionReply(code) {
-		return ftpError{code: code, msg: msg}
+		return pconn.replyError(code, msg)
 	}
 
 	return nil
 }
 
+func (pconn *persistentConn) getwd() (string, error) {
+	code, msg, err := pconn.sendCommand("PWD")
+	if err != nil {
+		return "", err
+	}
+
+	if code != replyDirCreated {
+		return "", pconn.replyError(code, msg)
+	}
+
+	dir, err := extractDirName(msg)
+	return dir, pconn.addContext(err)
+}
+
 // Request that the server enters passive mode, allowing us to connect to it.
 // This lets transfers work with the client behind NAT, so you almost always
 // want it. First try EPSV, then fall back to PASV.
@@ -312,12 +502,13 @@ PASV:
 	}
 
 	if code != replyEnteringPassiveMode {
-		return "", ftpError{code: code, msg: msg}
+		return "", pconn.replyError(code, msg)
 	}
 
-	parseError := ftpError{
-		err: fmt.Errorf("error parsing PASV response (%s)", msg),
-	}
+	parseError := pconn.withContext(ftpError{
+		err:  fmt.Errorf("error parsing PASV response (%s)", msg),
+		kind: KindProtocol,
+	})
 
 	// "Entering Passive Mode (162,138,208,11,223,57)."
 	startIdx = strings.Index(msg, "(")
@@ -385,10 +576,10 @@ func (pconn *persistentConn) prepareDataConn() (func() (net.Conn, error), error)
 				if ne, ok := netErr.(net.Error); ok {
 					isTemporary = ne.Temporary()
 				}
-				return nil, ftpError{err: netErr, temporary: isTemporary}
+				return nil, pconn.withContext(ftpError{err: netErr, temporary: isTemporary, timeout: isTimeout(netErr), kind: KindDataConn})
 			}
 
-			if pconn.config.TLSConfig != nil {
+			if pconn.dataTLS() {
 				dc = tls.Server(dc, pconn.config.TLSConfig)
 				pconn.debug("upgraded active connection to TLS")
 			}
@@ -413,15 +604,21 @@ func (pconn *persistentConn) prepareDataConn() (func() (net.Conn, error), error)
 			if ne, ok := netErr.(net.Error); ok {
 				isTemporary = ne.Temporary()
 			}
-			return nil, ftpError{err: netErr, temporary: isTemporary}
-		}
-
-		if pconn.config.TLSConfig != nil {
-			pconn.debug("upgrading data connection to TLS")
-			dc = tls.Client(dc, pconn.config.TLSConfig)
+			return nil, pconn.withContext(ftpError{err: netErr, temporary: isTemporary, timeout: isTimeout(netErr), kind: KindDataConn})
 		}
 
 		return func() (net.Conn, error) {
+			if pconn.dataTLS() {
+				pconn.debug("upgrading data connection to TLS")
+				tlsConn := tls.Client(dc, pconn.config.TLSConfig)
+				if err := pconn.handshake(tlsConn); err != nil {
+					dc.Close()
+					pconn.broken = true
+					return nil, err
+				}
+				dc = tlsConn
+			}
+
 			pconn.dataConn = &dataConn{
 				Conn:    dc,
 				Timeout: pconn.config.Timeout,
@@ -437,7 +634,7 @@ func (pconn *persistentConn) listenActive() (*net.TCPListener, error) {
 	localAddr := pconn.controlConn.LocalAddr().String()
 	localHost, localPort, err := net.SplitHostPort(localAddr)
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf("error splitting local address: %s (%s)", err, localAddr)}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("error splitting local address: %s (%s)", err, localAddr)})
 	}
 
 	if listenAddr == ":" {
@@ -450,28 +647,28 @@ func (pconn *persistentConn) listenActive() (*net.TCPListener, error) {
 
 	tcpAddr, err := net.ResolveTCPAddr("tcp", listenAddr)
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf("error parsing active listen addr: %s (%s)", err, listenAddr)}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("error parsing active listen addr: %s (%s)", err, listenAddr)})
 	}
 
 	listener, err := net.ListenTCP("tcp", tcpAddr)
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf("error listening on %s for active transfer: %s", listenAddr, err)}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("error listening on %s for active transfer: %s", listenAddr, err)})
 	}
 	pconn.debug("listening on %s for active connection", listener.Addr().String())
 
 	listenHost, listenPortStr, err := net.SplitHostPort(listener.Addr().String())
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf("error splitting listener addr: %s (%s)", err, listener.Addr().String())}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("error splitting listener addr: %s (%s)", err, listener.Addr().String())})
 	}
 
 	listenPort, err := strconv.Atoi(listenPortStr)
 	if err != nil {
-		return nil, ftpError{err: fmt.Errorf("error parsing listen port: %s (%s)", err, listenPortStr)}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("error parsing listen port: %s (%s)", err, listenPortStr)})
 	}
 
 	hostIP := net.ParseIP(listenHost)
 	if hostIP == nil {
-		return nil, ftpError{err: fmt.Errorf("failed parsing host IP %s", listenHost)}
+		return nil, pconn.withContext(ftpError{err: fmt.Errorf("failed parsing host IP %s", listenHost)})
 	}
 
 	hostIPv4 := hostIP.To4()
@@ -507,27 +704,37 @@ func (pconn *persistentConn) setType(t string) error {
 func (pconn *persistentConn) logInTLS() error {
 	err := pconn.sendCommandExpected(replyAuthOkayNoDataNeeded, "AUTH TLS")
 	if err != nil {
+		if pconn.config.TLSPolicy == TLSPrefer && errors.Is(err, ErrNotSupported) {
+			pconn.debug("server doesn't support TLS, continuing in plaintext: %s", err)
+			return pconn.logIn()
+		}
 		return err
 	}
 
-	pconn.setControlConn(tls.Client(pconn.controlConn, pconn.config.TLSConfig))
-
-	err = pconn.logIn()
-	if err != nil {
+	tlsConn := tls.Client(pconn.controlConn, pconn.config.TLSConfig)
+	if err := pconn.handshake(tlsConn); err != nil {
+		pconn.broken = true
 		return err
 	}
 
-	err = pconn.sendCommandExpected(replyGroupPositiveCompletion, "PBSZ 0")
+	pconn.setControlConn(tlsConn)
+	pconn.tlsNegotiated = true
+
+	err = pconn.logIn()
 	if err != nil {
 		return err
 	}
 
-	err = pconn.sendCommandExpected(replyGroupPositiveCompletion, "PROT P")
+	err = pconn.setDataProtection()
 	if err != nil {
 		return err
 	}
 
 	pconn.debug("successfully upgraded to TLS")
 
+	if pconn.config.ClearControlChannel {
+		return pconn.clearControlChannel()
+	}
+
 	return nil
 }
@@ -0,0 +1,153 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMLSTFeature(t *testing.T) {
+	available, enabled := parseMLSTFeature("type*;size*;modify*;UNIX.mode;perm;unique*;")
+
+	if !reflect.DeepEqual(available, []string{"type", "size", "modify", "UNIX.mode", "perm", "unique"}) {
+		t.Errorf("got %v", available)
+	}
+
+	expEnabled := map[string]bool{"type": true, "size": true, "modify": true, "unique": true}
+	if !reflect.DeepEqual(enabled, expEnabled) {
+		t.Errorf("got %v", enabled)
+	}
+}
+
+func TestNegotiateMLST(t *testing.T) {
+	pconn := &persistentConn{
+		config: Config{
+			MLSTFacts: DefaultMLSTFacts,
+			stubResponses: map[string]stubResponse{
+				"OPTS MLST type;size;modify;UNIX.mode;perm;unique;": {200, "MLST OPTS type;size;modify;UNIX.mode;perm;unique;"},
+			},
+		},
+		features: map[string]string{
+			"MLST": "type*;size*;modify*;UNIX.mode;perm;unique;UNIX.owner;",
+		},
+	}
+
+	if err := pconn.negotiateMLST(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pconn.features["MLST"]; got != "type*;size*;modify*;UNIX.mode*;perm*;unique*;UNIX.owner;" {
+		t.Errorf("got %s", got)
+	}
+
+	// everything we want is enabled now, so this must not send a command
+	// (there's no stub for it)
+	pconn.config.stubResponses = nil
+	if err := pconn.negotiateMLST(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNegotiateMLSTKeepsEnabledFacts(t *testing.T) {
+	// like proftpd, which enables its UNIX.* facts by default
+	pconn := &persistentConn{
+		config: Config{
+			MLSTFacts: DefaultMLSTFacts,
+			stubResponses: map[string]stubResponse{
+				"OPTS MLST modify;perm;size;type;unique;UNIX.group;UNIX.groupname;UNIX.mode;UNIX.owner;UNIX.ownername;": {200, "OPTS MLST modify;perm;size;type;unique;UNIX.group;UNIX.groupname;UNIX.mode;UNIX.owner;UNIX.ownername;"},
+			},
+		},
+		features: map[string]string{
+			"MLST": "modify*;perm*;size*;type*;unique*;UNIX.group*;UNIX.groupname*;UNIX.mode;UNIX.owner*;UNIX.ownername*;",
+		},
+	}
+
+	if err := pconn.negotiateMLST(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := pconn.features["MLST"]; got != "modify*;perm*;size*;type*;unique*;UNIX.group*;UNIX.groupname*;UNIX.mode*;UNIX.owner*;UNIX.ownername*;" {
+		t.Errorf("got %s", got)
+	}
+
+	// nothing wanted is missing, so no "OPTS MLST" (there's no stub for it)
+	pconn.config.stubResponses = nil
+	pconn.features["MLST"] = "modify*;perm*;size*;type*;unique*;UNIX.mode*;UNIX.owner*;"
+	if err := pconn.negotiateMLST(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchSystem(t *testing.T) {
+	pconn := &persistentConn{
+		config: Config{
+			stubResponses: map[string]stubResponse{
+				"SYST": {215, "MVS is the operating system of this server. FTP Server is running on z/OS."},
+			},
+		},
+	}
+
+	if err := pconn.fetchSystem(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pconn.system != "MVS is the operating system of this server. FTP Server is running on z/OS." {
+		t.Errorf("got %q", pconn.system)
+	}
+
+	if f := pconn.systemListFormat(); f == nil || reflect.ValueOf(f).Pointer() != reflect.ValueOf(ListParserFunc(parseMVSLIST)).Pointer() {
+		t.Error("expected MVS format")
+	}
+
+	for _, system := range []string{"UNIX Type: L8", ""} {
+		pconn.system = system
+		if pconn.systemListFormat() != nil {
+			t.Errorf("%q: expected no preferred format", system)
+		}
+	}
+}
+
+func TestErrorContext(t *testing.T) {
+	pconn := &persistentConn{
+		config: Config{
+			stubResponses: map[string]stubResponse{
+				"DELE foo.txt": {550, "foo.txt: No such file or directory"},
+				"PASS secret":  {530, "Login incorrect."},
+			},
+		},
+		host: "127.0.0.1:2121",
+		idx:  3,
+	}
+
+	err := pconn.sendCommandExpected(replyFileActionOkay, "DELE %s", "foo.txt")
+
+	fe, ok := err.(Error)
+	if !ok {
+		t.Fatalf("got %T", err)
+	}
+
+	if fe.Command() != "DELE" || fe.Argument() != "foo.txt" || fe.Host() != "127.0.0.1:2121" || fe.ConnIndex() != 3 {
+		t.Errorf("got %q %q %q %d", fe.Command(), fe.Argument(), fe.Host(), fe.ConnIndex())
+	}
+
+	exp := "#3 127.0.0.1:2121 DELE foo.txt: unexpected response: 550-foo.txt: No such file or directory"
+	if err.Error() != exp {
+		t.Errorf("got %q", err)
+	}
+
+	// context survives wrapping
+	wrapped := ftpError{err: fmt.Errorf("%w (can't resume)", err)}
+	if wrapped.Command() != "DELE" || wrapped.Code() != 550 {
+		t.Errorf("got %q %d", wrapped.Command(), wrapped.Code())
+	}
+
+	err = pconn.sendCommandExpected(replyUserLoggedIn, "PASS %s", "secret")
+	if fe := err.(Error); fe.Command() != "PASS" || fe.Argument() != "******" || strings.Contains(err.Error(), "secret") {
+		t.Errorf("password not redacted: %s", err)
+	}
+}
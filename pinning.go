@@ -0,0 +1,184 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// SPKIFingerprint returns the base64 encoded SHA-256 hash of a certificate's
+// SubjectPublicKeyInfo, the format of Config.TLSPins and known hosts files.
+// It matches the output of:
+//
+//	openssl x509 -noout -pubkey | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func SPKIFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Make pconn's TLS connections check the server certificate against
+// Config.TLSPins and "knownHosts" (nil if Config.TLSKnownHostsFile isn't
+// set), after any VerifyConnection of the user's.
+func (pconn *persistentConn) pinCertificates(knownHosts *knownHosts) {
+	pins := pconn.config.TLSPins[pconn.serverName]
+
+	if len(pins) == 0 && knownHosts == nil {
+		return
+	}
+
+	userVerify := pconn.config.TLSConfig.VerifyConnection
+
+	pconn.config.TLSConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if userVerify != nil {
+			if err := userVerify(cs); err != nil {
+				return err
+			}
+		}
+
+		// we are the TLS server on active data connections, and the server
+		// doesn't present a certificate there
+		if len(cs.PeerCertificates) == 0 {
+			return nil
+		}
+
+		fingerprint := SPKIFingerprint(cs.PeerCertificates[0])
+
+		if len(pins) > 0 && !containsString(pins, fingerprint) {
+			pconn.debug("certificate of %s (%s) not pinned", pconn.serverName, fingerprint)
+			return &tls.CertificateVerificationError{
+				UnverifiedCertificates: cs.PeerCertificates,
+				Err:                    fmt.Errorf("certificate of %s doesn't match pinned keys (got %s)", pconn.serverName, fingerprint),
+			}
+		}
+
+		if knownHosts != nil {
+			err := knownHosts.check(pconn, knownHostKey(pconn.serverName, pconn.host), fingerprint)
+			if err != nil {
+				return &tls.CertificateVerificationError{UnverifiedCertificates: cs.PeerCertificates, Err: err}
+			}
+		}
+
+		return nil
+	}
+}
+
+// knownHosts is a Client's copy of Config.TLSKnownHostsFile. The file is
+// read on first use; new hosts are added to both the copy and the file. Each
+// line of the file is a host and a fingerprint separated by a space, and a
+// host may have several lines. Hosts on ports other than 21 are written
+// "[host]:port", like in ssh's known_hosts.
+type knownHosts struct {
+	mu    sync.Mutex
+	path  string
+	hosts map[string][]string
+}
+
+func newKnownHosts(path string) *knownHosts {
+	return &knownHosts{path: path}
+}
+
+// The known hosts entry for "serverName" at address "addr".
+func knownHostKey(serverName, addr string) string {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "21" {
+		return serverName
+	}
+	return fmt.Sprintf("[%s]:%s", serverName, port)
+}
+
+// Check "fingerprint" against the known fingerprints of "host", recording it
+// if "host" is new.
+func (kh *knownHosts) check(pconn *persistentConn, host, fingerprint string) error {
+	kh.mu.Lock()
+	defer kh.mu.Unlock()
+
+	if kh.hosts == nil {
+		hosts, err := readKnownHosts(kh.path)
+		if err != nil {
+			return err
+		}
+		kh.hosts = hosts
+	}
+
+	if fingerprints, found := kh.hosts[host]; found {
+		if !containsString(fingerprints, fingerprint) {
+			pconn.debug("certificate of %s changed to %s", host, fingerprint)
+			return fmt.Errorf("certificate of %s changed: got %s, expected one of %s from %s",
+				host,
+				fingerprint,
+				strings.Join(fingerprints, ", "),
+				kh.path,
+			)
+		}
+		return nil
+	}
+
+	f, err := os.OpenFile(kh.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "%s %s\n", host, fingerprint)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	kh.hosts[host] = append(kh.hosts[host], fingerprint)
+
+	pconn.debug("recorded certificate of %s (%s) in %s", host, fingerprint, kh.path)
+
+	return nil
+}
+
+func readKnownHosts(path string) (map[string][]string, error) {
+	known := make(map[string][]string)
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return known, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: invalid known host %q", path, lineNum, line)
+		}
+
+		known[fields[0]] = append(known[fields[0]], fields[1])
+	}
+
+	return known, scanner.Err()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
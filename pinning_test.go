@@ -0,0 +1,152 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func verifyTestPeer(t *testing.T, pconn *persistentConn, cert tls.Certificate) error {
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{mustParseCertificate(t, cert)}}
+	return pconn.config.TLSConfig.VerifyConnection(state)
+}
+
+func TestTLSPins(t *testing.T) {
+	cert, other := testCertificate(t), testCertificate(t)
+	fingerprint := SPKIFingerprint(mustParseCertificate(t, cert))
+
+	pconn := &persistentConn{
+		config: Config{
+			TLSConfig: &tls.Config{InsecureSkipVerify: true},
+			TLSPins:   map[string][]string{"localhost": {"bogus", fingerprint}},
+		},
+		serverName: "localhost",
+	}
+	pconn.pinCertificates(nil)
+
+	if err := verifyTestPeer(t, pconn, cert); err != nil {
+		t.Error(err)
+	}
+
+	if err := verifyTestPeer(t, pconn, other); err == nil {
+		t.Error("expected error for unpinned certificate")
+	}
+
+	// no pins for this host
+	pconn = &persistentConn{
+		config:     Config{TLSConfig: &tls.Config{}, TLSPins: pconn.config.TLSPins},
+		serverName: "127.0.0.1",
+	}
+	pconn.pinCertificates(nil)
+
+	if pconn.config.TLSConfig.VerifyConnection != nil {
+		t.Error("expected no verification")
+	}
+}
+
+func TestTLSKnownHosts(t *testing.T) {
+	cert, other := testCertificate(t), testCertificate(t)
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	hosts := newKnownHosts(path)
+
+	newConn := func(hosts *knownHosts, addr string) *persistentConn {
+		pconn := &persistentConn{
+			config:     Config{TLSConfig: &tls.Config{InsecureSkipVerify: true}},
+			host:       addr,
+			serverName: "localhost",
+		}
+		pconn.pinCertificates(hosts)
+		return pconn
+	}
+
+	// first use records the fingerprint
+	if err := verifyTestPeer(t, newConn(hosts, "127.0.0.1:21"), cert); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyTestPeer(t, newConn(hosts, "127.0.0.1:21"), cert); err != nil {
+		t.Error(err)
+	}
+
+	if err := verifyTestPeer(t, newConn(hosts, "127.0.0.1:21"), other); err == nil {
+		t.Error("expected error for changed certificate")
+	}
+
+	// a different server on another port
+	if err := verifyTestPeer(t, newConn(hosts, "127.0.0.1:2124"), other); err != nil {
+		t.Error(err)
+	}
+
+	// a new client reads what was recorded
+	hosts = newKnownHosts(path)
+
+	if err := verifyTestPeer(t, newConn(hosts, "127.0.0.1:21"), other); err == nil {
+		t.Error("expected error for changed certificate")
+	}
+
+	if err := verifyTestPeer(t, newConn(hosts, "127.0.0.1:2124"), other); err != nil {
+		t.Error(err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "localhost " + SPKIFingerprint(mustParseCertificate(t, cert)) + "\n" +
+		"[localhost]:2124 " + SPKIFingerprint(mustParseCertificate(t, other)) + "\n"
+	if string(contents) != exp {
+		t.Errorf("got %q", contents)
+	}
+}
+
+func TestTLSPinHandshake(t *testing.T) {
+	cert := testCertificate(t)
+	addr, _ := serveTLSOnce(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pconn := &persistentConn{
+		config: Config{
+			Timeout:   5 * time.Second,
+			TLSConfig: connTLSConfig(&tls.Config{InsecureSkipVerify: true}, "localhost"),
+			TLSPins:   map[string][]string{"localhost": {"bogus"}},
+			stubResponses: map[string]stubResponse{
+				"AUTH TLS": {234, "AUTH TLS successful"},
+			},
+		},
+		host:       addr,
+		serverName: "localhost",
+	}
+	pconn.setControlConn(conn)
+	defer pconn.controlConn.Close()
+
+	pconn.pinCertificates(nil)
+
+	err = pconn.logInTLS()
+
+	fe, ok := err.(Error)
+	if !ok {
+		t.Fatalf("got %T %v", err, err)
+	}
+
+	if fe.Kind() != KindAuth || fe.Temporary() || fe.Host() != addr {
+		t.Errorf("got %s (kind %s, temporary %v)", err, fe.Kind(), fe.Temporary())
+	}
+
+	if !pconn.broken || pconn.tlsNegotiated {
+		t.Error("expected broken connection without TLS")
+	}
+}
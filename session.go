@@ -0,0 +1,211 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Session is a single connection checked out of a Client's pool for
+// exclusive use. Unlike the Client, a Session has a meaningful working
+// directory: relative paths passed to its methods are resolved by the
+// server against the directory set with Chdir. Session methods are safe to
+// call concurrently, but calls are serialized on the one connection. Close
+// the Session to return the connection to the Client's pool.
+//
+// Sessions don't use the Client's metadata cache (see MetadataCacheTTL).
+// Since their paths may be relative to a different directory, changes made
+// through a Session clear the Client's cache entirely.
+type Session struct {
+	parent *Client
+
+	// single-connection client all operations go through
+	client *Client
+
+	// working directory to restore before returning to the pool
+	initialDir string
+}
+
+// Session checks out a connection from the pool for exclusive use. It blocks
+// like any other Client method if all connections are busy.
+func (c *Client) Session() (*Session, error) {
+	pconn, err := c.getIdleConn()
+	if err != nil {
+		return nil, err
+	}
+
+	initialDir, err := pconn.getwd()
+	if err != nil {
+		c.returnConn(pconn)
+		return nil, err
+	}
+
+	config := c.config
+	config.ConnectionsPerHost = 1
+
+	client := &Client{
+		config:          config,
+		freeConnCh:      make(chan *persistentConn, 1),
+		t0:              c.t0,
+		hosts:           []hostAddr{pconn.hostAddr()},
+		allCons:         map[int]*persistentConn{pconn.idx: pconn},
+		numConnsPerHost: map[hostAddr]int{pconn.hostAddr(): 1},
+		pinned:          pconn,
+		locations:       c.locations,
+		knownHosts:      c.knownHosts,
+	}
+	client.freeConnCh <- pconn
+
+	c.debug("#%d checked out for session", pconn.idx)
+
+	return &Session{
+		parent:     c,
+		client:     client,
+		initialDir: initialDir,
+	}, nil
+}
+
+// Get the pinned connection, waiting for any in-progress operation on it to
+// complete.
+func (c *Client) getPinnedConn() (*persistentConn, error) {
+	pconn, ok := <-c.freeConnCh
+	if !ok {
+		return nil, ftpError{err: fmt.Errorf("session %w", ErrClosed)}
+	}
+
+	if pconn.broken {
+		c.returnConn(pconn)
+		return nil, ftpError{err: errors.New("session connection is broken")}
+	}
+
+	return pconn, nil
+}
+
+// Close returns the Session's connection to the Client's pool, restoring its
+// original working directory first.
+func (s *Session) Close() error {
+	pconn, ok := <-s.client.freeConnCh
+	if !ok {
+		return ftpError{err: fmt.Errorf("already %w", ErrClosed)}
+	}
+	close(s.client.freeConnCh)
+
+	var err error
+	if !pconn.broken {
+		err = pconn.sendCommandExpected(replyFileActionOkay, "CWD %s", s.initialDir)
+		if err != nil {
+			pconn.broken = true
+		}
+	}
+
+	s.parent.debug("#%d returned from session", pconn.idx)
+	s.parent.returnConn(pconn)
+
+	return err
+}
+
+// Chdir changes the Session's working directory to "path".
+func (s *Session) Chdir(path string) error {
+	pconn, err := s.client.getIdleConn()
+	if err != nil {
+		return err
+	}
+
+	defer s.client.returnConn(pconn)
+
+	return pconn.sendCommandExpected(replyFileActionOkay, "CWD %s", path)
+}
+
+// Getwd returns the Session's working directory.
+func (s *Session) Getwd() (string, error) {
+	return s.client.Getwd()
+}
+
+// TLSNegotiated reports whether the Session's connection negotiated TLS. With
+// TLSPrefer, connections to different hosts may differ, so audit each
+// Session (or RawConn) rather than the Client.
+func (s *Session) TLSNegotiated() (bool, error) {
+	pconn, err := s.client.getIdleConn()
+	if err != nil {
+		return false, err
+	}
+
+	defer s.client.returnConn(pconn)
+
+	return pconn.tlsNegotiated, nil
+}
+
+// Delete deletes the file "path". See Client.Delete.
+func (s *Session) Delete(path string) error {
+	defer s.parent.cache.clear()
+	return s.client.Delete(path)
+}
+
+// Rename renames file "from" to "to". See Client.Rename.
+func (s *Session) Rename(from, to string) error {
+	defer s.parent.cache.clear()
+	return s.client.Rename(from, to)
+}
+
+// Mkdir creates directory "path". See Client.Mkdir.
+func (s *Session) Mkdir(path string) (string, error) {
+	defer s.parent.cache.clear()
+	return s.client.Mkdir(path)
+}
+
+// Rmdir removes directory "path". See Client.Rmdir.
+func (s *Session) Rmdir(path string) error {
+	defer s.parent.cache.clear()
+	return s.client.Rmdir(path)
+}
+
+// ReadDir fetches the contents of a directory. See Client.ReadDir.
+func (s *Session) ReadDir(path string) ([]os.FileInfo, error) {
+	return s.client.ReadDir(path)
+}
+
+// ReadDirAll lists a directory including hidden files. See
+// Client.ReadDirAll.
+func (s *Session) ReadDirAll(path string) ([]os.FileInfo, error) {
+	return s.client.ReadDirAll(path)
+}
+
+// ReadDirFunc streams the contents of a directory to "fn". See
+// Client.ReadDirFunc.
+func (s *Session) ReadDirFunc(path string, fn func(os.FileInfo) error) error {
+	return s.client.ReadDirFunc(path, fn)
+}
+
+// ReadDirNames fetches the names of the entries in a directory. See
+// Client.ReadDirNames.
+func (s *Session) ReadDirNames(path string) ([]string, error) {
+	return s.client.ReadDirNames(path)
+}
+
+// Stat fetches details for a particular file. See Client.Stat.
+func (s *Session) Stat(path string) (os.FileInfo, error) {
+	return s.client.Stat(path)
+}
+
+// Readlink returns the target of symlink "path". See Client.Readlink.
+func (s *Session) Readlink(path string) (string, error) {
+	return s.client.Readlink(path)
+}
+
+// Retrieve file "path" from server and write bytes to "dest". See
+// Client.Retrieve.
+func (s *Session) Retrieve(path string, dest io.Writer) error {
+	return s.client.Retrieve(path, dest)
+}
+
+// Store bytes read from "src" into file "path" on the server. See
+// Client.Store.
+func (s *Session) Store(path string, src io.Reader) error {
+	defer s.parent.cache.clear()
+	return s.client.Store(path, src)
+}
@@ -0,0 +1,87 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"bytes"
+	"path"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	for _, addr := range ftpdAddrs {
+		c, err := DialConfig(goftpConfig, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rootDir, err := c.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sess, err := c.Session()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sess.Chdir("subdir"); err != nil {
+			t.Fatal(err)
+		}
+
+		cwd, err := sess.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cwd != path.Join(rootDir, "subdir") {
+			t.Errorf("Unexpected cwd: %s", cwd)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := sess.Retrieve("1234.bin", buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal([]byte{1, 2, 3, 4}, buf.Bytes()) {
+			t.Errorf("Got %v", buf.Bytes())
+		}
+
+		list, err := sess.ReadDir("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(list) != 1 || list[0].Name() != "1234.bin" {
+			t.Errorf("Unexpected listing: %v", list)
+		}
+
+		if err := sess.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sess.Close(); err == nil {
+			t.Error("expected error closing twice")
+		}
+
+		if _, err := sess.Getwd(); err == nil {
+			t.Error("expected error using closed session")
+		}
+
+		// connection went back to the pool with its original directory
+		cwd, err = c.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cwd != rootDir {
+			t.Errorf("Session leaked cwd %s", cwd)
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
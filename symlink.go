@@ -0,0 +1,178 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Maximum number of links followed when resolving a symlink.
+const maxSymlinkHops = 40
+
+// Readlink returns the target of symlink "path" as reported by the server,
+// either via the MLST "type=OS.unix=slink:<target>" fact or the
+// "name -> target" LIST format. Note that some servers follow symlinks when
+// answering MLST, in which case Readlink will report that "path" is not a
+// symlink.
+func (c *Client) Readlink(path string) (string, error) {
+	info, err := c.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	f, ok := info.(*ftpFile)
+	if !ok || f.mode&os.ModeSymlink == 0 {
+		return "", ftpError{err: fmt.Errorf("%s is not a symlink", path)}
+	}
+
+	if f.linkTarget == "" {
+		return "", ftpError{err: fmt.Errorf("server did not report target of symlink %s", path)}
+	}
+
+	return f.linkTarget, nil
+}
+
+// Mark symlinks in "infos" (the contents of "dir") that point to directories.
+// Links to "dir" itself or one of its ancestors are left alone, since
+// recursive walks would loop on them.
+func (c *Client) resolveLinks(pconn *persistentConn, dir string, infos []os.FileInfo) {
+	var cwd string
+
+	for _, info := range infos {
+		f, ok := info.(*ftpFile)
+		if !ok || f.mode&os.ModeSymlink == 0 {
+			continue
+		}
+
+		linkPath := path.Join(dir, f.name)
+		target, isDir, err := c.resolveLink(pconn, linkPath, f)
+		if err != nil {
+			pconn.debug("failed resolving symlink %s: %s", linkPath, err)
+			continue
+		}
+
+		if !isDir {
+			continue
+		}
+
+		if target != "" {
+			if cwd == "" && !(path.IsAbs(dir) && path.IsAbs(target)) {
+				if cwd, err = pconn.getwd(); err != nil {
+					pconn.debug("failed resolving symlink %s: %s", linkPath, err)
+					continue
+				}
+			}
+
+			if isAncestor(absPath(cwd, target), absPath(cwd, dir)) {
+				pconn.debug("symlink %s points to ancestor %s", linkPath, target)
+				continue
+			}
+		}
+
+		f.mode |= os.ModeDir
+	}
+}
+
+// Follow the chain of symlinks starting at "link" and report whether it ends
+// at a directory, and the path it ends at (empty string if the server
+// followed the links for us).
+func (c *Client) resolveLink(pconn *persistentConn, linkPath string, link *ftpFile) (string, bool, error) {
+	if link.linkTarget == "" || !pconn.hasFeature("MLST") {
+		// we can't (reliably) stat directories without MLST, so let the
+		// server chase the link for us
+		isDir, err := c.probeDir(pconn, linkPath)
+		if link.linkTarget == "" {
+			return "", isDir, err
+		}
+
+		// we still know the first hop
+		return joinLinkTarget(linkPath, link.linkTarget), isDir, err
+	}
+
+	seen := map[string]bool{linkPath: true}
+	for {
+		target := joinLinkTarget(linkPath, link.linkTarget)
+
+		if seen[target] {
+			return "", false, pconn.withContext(ftpError{err: fmt.Errorf("symlink loop at %s", target)})
+		}
+
+		if len(seen) > maxSymlinkHops {
+			return "", false, pconn.withContext(ftpError{err: fmt.Errorf("too many levels of symlinks at %s", target)})
+		}
+
+		seen[target] = true
+
+		info, err := c.stat(pconn, target)
+		if err != nil {
+			return "", false, err
+		}
+
+		f, ok := info.(*ftpFile)
+		if !ok || f.mode&os.ModeSymlink == 0 {
+			return target, info.IsDir(), nil
+		}
+
+		if f.linkTarget == "" {
+			isDir, err := c.probeDir(pconn, target)
+			return "", isDir, err
+		}
+
+		linkPath, link = target, f
+	}
+}
+
+// The path symlink "linkPath" with target "target" points to.
+func joinLinkTarget(linkPath, target string) string {
+	if path.IsAbs(target) {
+		return path.Clean(target)
+	}
+	return path.Join(path.Dir(linkPath), target)
+}
+
+// Make "p" absolute relative to working directory "cwd".
+func absPath(cwd, p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(cwd, p)
+}
+
+// Report whether absolute path "dir" is "p" or one of its ancestors.
+func isAncestor(dir, p string) bool {
+	return dir == "/" || dir == p || strings.HasPrefix(p, dir+"/")
+}
+
+// Determine whether "path" is a directory by trying to change into it. The
+// connection's working directory is restored afterwards.
+func (c *Client) probeDir(pconn *persistentConn, path string) (bool, error) {
+	cwd, err := pconn.getwd()
+	if err != nil {
+		return false, err
+	}
+
+	code, _, err := pconn.sendCommand("CWD %s", path)
+	if err != nil {
+		return false, err
+	}
+
+	if code != replyFileActionOkay {
+		// not a directory, or the server gave up following the link
+		return false, nil
+	}
+
+	err = pconn.sendCommandExpected(replyFileActionOkay, "CWD %s", cwd)
+	if err != nil {
+		// don't put a connection with the wrong working directory back in
+		// the pool
+		pconn.broken = true
+		return true, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// tlsSessionCache is a tls.ClientSessionCache holding the TLS session of one
+// persistentConn. Many servers (e.g. vsftpd with require_ssl_reuse, ProFTPD
+// and FileZilla Server) reject data connections that don't resume the control
+// connection's session. crypto/tls keys sessions by server name, or by
+// address if ServerName isn't set, and data connections go to a different
+// port, so the cache ignores the key and always returns the latest session.
+type tlsSessionCache struct {
+	mu      sync.Mutex
+	session *tls.ClientSessionState
+}
+
+func (sc *tlsSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.session, sc.session != nil
+}
+
+func (sc *tlsSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.session = cs
+}
+
+// Copy "config" for one connection. The copy has a session cache of its own,
+// so data connections resume the control connection's TLS session, and
+// verifies certificates against "serverName" unless ServerName is already
+// set.
+func connTLSConfig(config *tls.Config, serverName string) *tls.Config {
+	config = config.Clone()
+	config.ClientSessionCache = &tlsSessionCache{}
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+	return config
+}
+
+// Complete the TLS handshake on "conn" now rather than on its first read or
+// write, so that certificate problems aren't reported as temporary I/O
+// errors and retried.
+func (pconn *persistentConn) handshake(conn *tls.Conn) error {
+	conn.SetDeadline(time.Now().Add(pconn.config.Timeout))
+	err := conn.Handshake()
+	conn.SetDeadline(time.Time{})
+
+	if err != nil {
+		pconn.debug("TLS handshake failed: %s", err)
+		return pconn.withContext(ftpError{
+			err:     fmt.Errorf("TLS handshake failed: %w", err),
+			timeout: isTimeout(err),
+			kind:    tlsErrorKind(err),
+		})
+	}
+
+	return nil
+}
+
+// Classify a TLS handshake error. Failing certificate verification (including
+// Config.TLSPins and Config.TLSKnownHostsFile) is KindAuth.
+func tlsErrorKind(err error) ErrorKind {
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return KindAuth
+	}
+	return KindOther
+}
+
+// Go back to plaintext on the control connection with "CCC" (RFC 4217
+// section 6). Data connections keep using TLS.
+func (pconn *persistentConn) clearControlChannel() error {
+	tlsConn, ok := pconn.controlConn.(*tls.Conn)
+	if !ok {
+		return pconn.withContext(ftpError{err: errors.New("control connection isn't using TLS")})
+	}
+
+	err := pconn.sendCommandExpected(replyCommandOkay, "CCC")
+	if err != nil {
+		return err
+	}
+
+	// both sides end the TLS session with a close_notify alert
+	if err := tlsConn.CloseWrite(); err != nil {
+		pconn.broken = true
+		return pconn.withContext(ftpError{
+			err:       fmt.Errorf("error ending TLS session: %s", err),
+			temporary: true,
+		})
+	}
+
+	tlsConn.SetReadDeadline(time.Now().Add(pconn.config.Timeout))
+	if _, err := tlsConn.Read(make([]byte, 1)); err != io.EOF {
+		pconn.debug("server didn't end TLS session after CCC: %v", err)
+	}
+
+	pconn.setControlConn(tlsConn.NetConn())
+
+	pconn.debug("cleared control channel")
+
+	return nil
+}
+
+// Negotiate the protection level of data connections with "PBSZ" and
+// "PROT" (RFC 4217 section 9).
+func (pconn *persistentConn) setDataProtection() error {
+	err := pconn.sendCommandExpected(replyGroupPositiveCompletion, "PBSZ 0")
+	if err != nil {
+		return err
+	}
+
+	return pconn.sendCommandExpected(replyGroupPositiveCompletion, "PROT %s", pconn.config.DataProtection)
+}
+
+// Whether data connections use TLS.
+func (pconn *persistentConn) dataTLS() bool {
+	return pconn.tlsNegotiated && pconn.config.DataProtection == DataProtectionPrivate
+}
@@ -0,0 +1,403 @@
+// Copyright 2015 Muir Manders.  All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package goftp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// A self-signed certificate for TLS servers in tests.
+func testCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// Accept one TLS connection, greet it and report whether it resumed a
+// session.
+func serveTLSOnce(t *testing.T, config *tls.Config) (string, <-chan bool) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := make(chan bool, 1)
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			resumed <- false
+			return
+		}
+
+		tlsConn := tls.Server(conn, config)
+		defer tlsConn.Close()
+
+		if err := tlsConn.Handshake(); err != nil {
+			resumed <- false
+			return
+		}
+
+		// TLS 1.3 session tickets are read along with this
+		tlsConn.Write([]byte("220 ready\r\n"))
+		resumed <- tlsConn.ConnectionState().DidResume
+
+		io.Copy(io.Discard, tlsConn)
+	}()
+
+	return l.Addr().String(), resumed
+}
+
+func dialTLS(t *testing.T, addr string, config *tls.Config) *tls.Conn {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if _, err := bufio.NewReader(tlsConn).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	return tlsConn
+}
+
+func TestTLSSessionResumption(t *testing.T) {
+	cert := testCertificate(t)
+
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		serverConfig := &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MaxVersion:   version,
+		}
+
+		// no ServerName, so crypto/tls would key sessions by address
+		clientConfig := connTLSConfig(&tls.Config{InsecureSkipVerify: true}, "")
+
+		controlAddr, controlResumed := serveTLSOnce(t, serverConfig)
+		control := dialTLS(t, controlAddr, clientConfig)
+		if <-controlResumed {
+			t.Errorf("%x: control connection resumed a session", version)
+		}
+
+		// different port, like a data connection
+		dataAddr, dataResumed := serveTLSOnce(t, serverConfig)
+		data := dialTLS(t, dataAddr, clientConfig)
+		if !<-dataResumed {
+			t.Errorf("%x: data connection didn't resume the control session", version)
+		}
+
+		data.Close()
+		control.Close()
+
+		// another connection's config has a separate cache
+		otherAddr, otherResumed := serveTLSOnce(t, serverConfig)
+		other := dialTLS(t, otherAddr, connTLSConfig(&tls.Config{InsecureSkipVerify: true}, ""))
+		if <-otherResumed {
+			t.Errorf("%x: unrelated connection resumed a session", version)
+		}
+		other.Close()
+	}
+}
+
+// ProFTPD requires data connections to reuse the control connection's TLS
+// session by default.
+func TestTLSSessionReuseRequired(t *testing.T) {
+	for _, addr := range proAddrs {
+		config := Config{
+			User:     "goftp",
+			Password: "rocks",
+			TLSConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+			TLSMode: TLSExplicit,
+		}
+
+		c, err := DialConfig(config, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a few data connections on the same control connection
+		for i := 0; i < 3; i++ {
+			if _, err := c.ReadDir(""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if c.numOpenConns() != len(c.freeConnCh) {
+			t.Error("Leaked a connection")
+		}
+	}
+}
+
+func TestClearControlChannel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- func() error {
+			conn, err := l.Accept()
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}})
+			tlsReader := bufio.NewReader(tlsConn)
+
+			if line, err := tlsReader.ReadString('\n'); err != nil || line != "CCC\r\n" {
+				return fmt.Errorf("expected CCC, got %q (%v)", line, err)
+			}
+
+			if _, err := tlsConn.Write([]byte("200 CCC OK\r\n")); err != nil {
+				return err
+			}
+
+			if err := tlsConn.CloseWrite(); err != nil {
+				return err
+			}
+
+			// CloseWrite leaves an expired write deadline behind
+			conn.SetWriteDeadline(time.Time{})
+
+			// Skip the client's close_notify record. Reading it through
+			// tlsConn might buffer the plaintext that follows.
+			header := make([]byte, 5)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				return err
+			}
+
+			if _, err := io.CopyN(io.Discard, conn, int64(header[3])<<8|int64(header[4])); err != nil {
+				return err
+			}
+
+			plainReader := bufio.NewReader(conn)
+			if line, err := plainReader.ReadString('\n'); err != nil || line != "NOOP\r\n" {
+				return fmt.Errorf("expected plaintext NOOP, got %q (%v)", line, err)
+			}
+
+			_, err = conn.Write([]byte("200 plaintext\r\n"))
+			return err
+		}()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pconn := &persistentConn{config: Config{Timeout: 5 * time.Second}}
+	pconn.setControlConn(tls.Client(conn, &tls.Config{InsecureSkipVerify: true}))
+	defer pconn.controlConn.Close()
+
+	if err := pconn.clearControlChannel(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := pconn.controlConn.(*tls.Conn); ok {
+		t.Error("control connection still uses TLS")
+	}
+
+	code, msg, err := pconn.sendCommand("NOOP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code != 200 || msg != "plaintext" {
+		t.Errorf("got %d-%s", code, msg)
+	}
+
+	if err := <-serverErr; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDataProtection(t *testing.T) {
+	for _, tc := range []struct {
+		protection DataProtection
+		prot       string
+		dataTLS    bool
+	}{
+		{DataProtectionPrivate, "PROT P", true},
+		{DataProtectionClear, "PROT C", false},
+	} {
+		pconn := &persistentConn{
+			config: Config{
+				TLSConfig:      &tls.Config{},
+				DataProtection: tc.protection,
+				stubResponses: map[string]stubResponse{
+					"PBSZ 0": {200, "PBSZ=0"},
+					tc.prot:  {200, "Protection level set"},
+				},
+			},
+			tlsNegotiated: true,
+		}
+
+		if err := pconn.setDataProtection(); err != nil {
+			t.Errorf("%s: %s", tc.protection, err)
+		}
+
+		if pconn.dataTLS() != tc.dataTLS {
+			t.Errorf("%s: expected dataTLS %v", tc.protection, tc.dataTLS)
+		}
+	}
+
+	pconn := &persistentConn{config: Config{TLSConfig: &tls.Config{}, DataProtection: DataProtectionPrivate}}
+	if pconn.dataTLS() {
+		t.Error("expected no data TLS without TLS on the control connection")
+	}
+}
+
+func TestTLSServerName(t *testing.T) {
+	hosts, err := lookupHosts([]string{"localhost:2121", "127.0.0.1:2122"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range hosts {
+		exp := "localhost"
+		if host.addr == "127.0.0.1:2122" {
+			exp = "127.0.0.1"
+		}
+		if host.serverName != exp {
+			t.Errorf("%s: got %q", host.addr, host.serverName)
+		}
+	}
+
+	// virtual hosts on the same address keep their own names
+	controlAddr, _ := serveGreetings(t)
+	c := newClient(Config{
+		ConnectionsPerHost: 1,
+		stubResponses: map[string]stubResponse{
+			"USER anonymous": {230, "Logged in"},
+			"FEAT":           {502, "Command not implemented"},
+			"SYST":           {502, "Command not implemented"},
+		},
+	}, []hostAddr{
+		{addr: controlAddr, serverName: "a.example.com"},
+		{addr: controlAddr, serverName: "b.example.com"},
+	})
+	defer c.Close()
+
+	names := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		pconn, err := c.getIdleConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[pconn.serverName] = true
+	}
+
+	if !names["a.example.com"] || !names["b.example.com"] {
+		t.Errorf("got %v", names)
+	}
+
+	cert := testCertificate(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(mustParseCertificate(t, cert))
+
+	// verifies the certificate even though we dial an IP
+	addr, _ := serveTLSOnce(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	dialTLS(t, addr, connTLSConfig(&tls.Config{RootCAs: roots}, "localhost")).Close()
+
+	// an explicit ServerName wins
+	if name := connTLSConfig(&tls.Config{ServerName: "ftp.example.com"}, "localhost").ServerName; name != "ftp.example.com" {
+		t.Errorf("got %q", name)
+	}
+}
+
+func mustParseCertificate(t *testing.T, cert tls.Certificate) *x509.Certificate {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestTLSPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy TLSPolicy
+		auth   stubResponse
+		ok     bool
+	}{
+		{TLSPrefer, stubResponse{500, "AUTH not understood"}, true},
+		{TLSPrefer, stubResponse{502, "Command not implemented"}, true},
+		{TLSPrefer, stubResponse{534, "Policy requires SSL"}, false},
+		{TLSRequire, stubResponse{500, "AUTH not understood"}, false},
+	} {
+		pconn := &persistentConn{
+			config: Config{
+				User:      "anonymous",
+				TLSConfig: &tls.Config{},
+				TLSPolicy: tc.policy,
+				stubResponses: map[string]stubResponse{
+					"AUTH TLS":       tc.auth,
+					"USER anonymous": {230, "Logged in"},
+				},
+			},
+		}
+
+		err := pconn.logInTLS()
+		if (err == nil) != tc.ok {
+			t.Errorf("%d %d: got %v", tc.policy, tc.auth.code, err)
+		}
+
+		if pconn.TLSNegotiated() {
+			t.Errorf("%d %d: expected no TLS", tc.policy, tc.auth.code)
+		}
+	}
+
+	// sessions report on their own connection
+	c := newClient(Config{stubResponses: map[string]stubResponse{
+		"PWD": {257, `"/" is the current directory`},
+	}}, []hostAddr{{addr: "127.0.0.1:21"}})
+
+	pconn := &persistentConn{config: c.config, tlsNegotiated: true}
+	c.pinned = pconn
+	c.freeConnCh <- pconn
+
+	s, err := c.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if negotiated, err := s.TLSNegotiated(); err != nil || !negotiated {
+		t.Errorf("got %v, %v", negotiated, err)
+	}
+}
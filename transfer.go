@@ -5,8 +5,10 @@
 package goftp
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"strconv"
 )
@@ -33,11 +35,11 @@ func (c *Client) Retrieve(path string, dest io.Writer) error {
 
 		if err == nil {
 			break
-		} else if n == 0 {
+		} else if n == 0 || isLocalError(err) {
 			return err
 		} else if !canResume {
 			return ftpError{
-				err:       fmt.Errorf("%s (can't resume)", err),
+				err:       fmt.Errorf("%w (can't resume)", err),
 				temporary: true,
 			}
 		}
@@ -61,6 +63,7 @@ func (c *Client) Retrieve(path string, dest io.Writer) error {
 // will also verify the remote file's size after the transfer if the server
 // supports the SIZE command.
 func (c *Client) Store(path string, src io.Reader) error {
+	defer c.cache.invalidate(path)
 
 	canResume := len(c.hosts) == 1 && c.canResume()
 
@@ -85,7 +88,7 @@ func (c *Client) Store(path string, src io.Reader) error {
 			}
 			if size == -1 {
 				return ftpError{
-					err:       fmt.Errorf("%s (resume failed)", err),
+					err:       fmt.Errorf("%w (resume failed)", err),
 					temporary: true,
 				}
 			}
@@ -98,7 +101,7 @@ func (c *Client) Store(path string, src io.Reader) error {
 					err,
 				)
 				return ftpError{
-					err:       fmt.Errorf("%s (resume failed)", err),
+					err:       fmt.Errorf("%w (resume failed)", err),
 					temporary: true,
 				}
 			}
@@ -111,6 +114,8 @@ func (c *Client) Store(path string, src io.Reader) error {
 
 		if err == nil {
 			break
+		} else if isLocalError(err) {
+			return err
 		} else if n == 0 {
 			return ftpError{
 				err:       err,
@@ -118,7 +123,7 @@ func (c *Client) Store(path string, src io.Reader) error {
 			}
 		} else if !canResume {
 			return ftpError{
-				err:       fmt.Errorf("%s (can't resume)", err),
+				err:       fmt.Errorf("%w (can't resume)", err),
 				temporary: true,
 			}
 		}
@@ -187,17 +192,28 @@ func (c *Client) transferFromOffset(path string, dest io.Writer, src io.Reader,
 	// to catch early returns
 	defer dc.Close()
 
+	data := &transferConn{Conn: dc}
 	if dest == nil {
-		dest = dc
+		dest = data
 	} else {
-		src = dc
+		src = data
 	}
 
 	n, err := io.Copy(dest, src)
 
 	if err != nil {
 		pconn.broken = true
-		return n, err
+
+		if data.err == nil {
+			return n, pconn.withContext(ftpError{err: localError{err}})
+		}
+
+		return n, pconn.withContext(ftpError{
+			err:       err,
+			temporary: true,
+			timeout:   isTimeout(err),
+			kind:      KindDataConn,
+		})
 	}
 
 	err = dc.Close()
@@ -213,7 +229,7 @@ func (c *Client) transferFromOffset(path string, dest io.Writer, src io.Reader,
 
 	if !positiveCompletionReply(code) {
 		pconn.debug("unexpected response after %s: %d (%s)", cmd, code, msg)
-		return n, ftpError{code: code, msg: msg}
+		return n, pconn.replyError(code, msg)
 	}
 
 	return n, nil
@@ -267,3 +283,42 @@ func (c *Client) canResume() bool {
 
 	return pconn.hasFeatureWithArg("REST", "STREAM")
 }
+
+// transferConn records the error of a failed read from or write to a data
+// connection, to tell it apart from errors of the caller's io.Reader or
+// io.Writer.
+type transferConn struct {
+	net.Conn
+	err error
+}
+
+func (tc *transferConn) Read(buf []byte) (int, error) {
+	n, err := tc.Conn.Read(buf)
+	if err != nil && err != io.EOF {
+		tc.err = err
+	}
+	return n, err
+}
+
+func (tc *transferConn) Write(buf []byte) (int, error) {
+	n, err := tc.Conn.Write(buf)
+	if err != nil {
+		tc.err = err
+	}
+	return n, err
+}
+
+// localError is an error of the caller's io.Reader or io.Writer during a
+// transfer. Resuming the transfer won't help, so it is returned as is.
+type localError struct {
+	error
+}
+
+func (e localError) Unwrap() error {
+	return e.error
+}
+
+func isLocalError(err error) bool {
+	var le localError
+	return errors.As(err, &le)
+}